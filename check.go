@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/dns"
+	"ddns-ipv6/httpclient"
+	"ddns-ipv6/iputil"
+)
+
+// checkReport 汇总 -check 各步骤的结果
+type checkReport struct {
+	passed, failed, skipped int
+}
+
+// pass 记录通过的步骤
+func (r *checkReport) pass(step, format string, args ...any) {
+	r.passed++
+	fmt.Printf("PASS  %s: %s\n", step, fmt.Sprintf(format, args...))
+}
+
+// fail 记录失败的步骤
+func (r *checkReport) fail(step string, err error) {
+	r.failed++
+	fmt.Printf("FAIL  %s: %v\n", step, err)
+}
+
+// skip 记录无法检查的步骤，不计为失败
+func (r *checkReport) skip(step, reason string) {
+	r.skipped++
+	fmt.Printf("SKIP  %s: %s\n", step, reason)
+}
+
+// runCheck 依次检查配置、IPv6 连通性、地址检测、服务商凭证(只读查询)与通知渠道，
+// 打印每一步的结果，全部通过时返回 true；不会修改任何 DNS 记录
+func runCheck(cfg *config.Config) bool {
+	report := &checkReport{}
+	defer func() {
+		fmt.Printf("\n%d passed, %d failed, %d skipped\n", report.passed, report.failed, report.skipped)
+	}()
+
+	if err := cfg.Validate(); err != nil {
+		report.fail("config", err)
+		return false
+	}
+	report.pass("config", "valid")
+	httpclient.SetUserAgent(cfg.UserAgent)
+
+	for _, target := range cfg.UpdateTargets() {
+		checkTarget(report, target)
+	}
+	return report.failed == 0
+}
+
+// checkProvider 以只读查询检查 cfg 中 dns.provider 的凭证与各记录是否存在
+func checkProvider(ctx context.Context, report *checkReport, prefix string, cfg *config.Config, families []ipFamily) {
+	provider, err := dns.NewProvider(*cfg, nil)
+	if err != nil {
+		report.fail(prefix+cfg.ProviderName(), err)
+		return
+	}
+	for _, family := range families {
+		for _, sub := range cfg.Domain.AllSubDomains() {
+			step := fmt.Sprintf("%s%s %s %s", prefix, cfg.ProviderName(), family.recordType, cfg.Domain.Hostname(sub))
+			exists, err := dns.CheckRecord(ctx, provider, *cfg, sub, family.recordType)
+			switch {
+			case errors.Is(err, dns.ErrCheckUnsupported):
+				report.skip(step, "credentials can only be verified by an update")
+			case err != nil:
+				report.fail(step, err)
+			case exists:
+				report.pass(step, "credentials accepted, record exists")
+			case cfg.Domain.CreateIfMissing:
+				report.pass(step, "credentials accepted, record will be created")
+			default:
+				report.fail(step, errors.New("credentials accepted, but the record does not exist and domain.createIfMissing is disabled"))
+			}
+		}
+	}
+}
+
+// checkTarget 检查单个更新目标
+func checkTarget(report *checkReport, cfg *config.Config) {
+	prefix := ""
+	if cfg.Name != "" {
+		prefix = cfg.Name + "/"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.CycleTimeout)*time.Second)
+	defer cancel()
+
+	if cfg.EnableIPv6 {
+		timeout := time.Duration(cfg.Network.ConnectivityTimeout) * time.Second
+		if source, err := iputil.CheckIPv6Connectivity(cfg.Network.ConnectivityHosts, timeout); err != nil {
+			report.fail(prefix+"IPv6 connectivity", err)
+		} else {
+			report.pass(prefix+"IPv6 connectivity", "reached %s", source)
+		}
+	}
+
+	families := enabledFamilies(cfg)
+	for _, family := range families {
+		step := fmt.Sprintf("%sdetect %s (%s)", prefix, family.name, family.detectionMethods(cfg.Network))
+		if ip, method, err := family.detect(ctx, cfg.Network); err != nil {
+			report.fail(step, err)
+		} else {
+			report.pass(step, "%s via %s", ip, method)
+		}
+	}
+
+	checkProvider(ctx, report, prefix, cfg, families)
+	if fallback := cfg.FallbackConfig(); fallback != nil {
+		checkProvider(ctx, report, prefix+"fallback ", fallback, families)
+	}
+
+	channels := 0
+	err := sendTestNotification(cfg, func(channel string, err error) {
+		channels++
+		if err != nil {
+			report.fail(prefix+"notify "+channel, err)
+		} else {
+			report.pass(prefix+"notify "+channel, "test message sent")
+		}
+	})
+	if err != nil {
+		report.fail(prefix+"notify", err)
+	} else if channels == 0 {
+		report.skip(prefix+"notify", "no notification channel enabled")
+	}
+}
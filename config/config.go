@@ -1,33 +1,436 @@
 package config
 
 import (
-	"log"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Tencent struct {
-		SecretId  string
-		SecretKey string
-	}
-	Domain struct {
-		Domain string
+	// File 本次加载的配置文件路径
+	File string `mapstructure:"-"`
+	// EnvOverrides 本次加载中由环境变量覆盖的配置项，环境变量优先于配置文件
+	EnvOverrides []string `mapstructure:"-"`
+	// Name 更新目标的名称，用于区分日志、缓存文件与健康状态
+	Name string
+	// Targets 独立调度的更新目标，各自使用自己的检查间隔、服务商、缓存与健康状态，为空时顶层配置本身即唯一目标
+	Targets []*Config `mapstructure:"-" json:",omitempty"`
 
-		SubDomain string
+	Tencent    Tencent
+	Cloudflare Cloudflare
+	Aliyun     Aliyun
+	DuckDNS    DuckDNS
+	Route53    Route53
+	GoDaddy    GoDaddy
+	Namecheap  Namecheap
+	Huawei     Huawei
+	DNS        struct {
+		// Provider DNS 服务商: tencent(默认)、cloudflare、aliyun、duckdns、route53、godaddy、namecheap 或 huawei
+		Provider string
+		// Resolver 解析服务商接口域名使用的 DNS 服务器(如 1.1.1.1 或局域网解析器)，为空时使用系统解析器
+		Resolver string
+		// Timeout 调用服务商接口的单个请求超时(秒)，默认 30
+		Timeout int
+		// Providers 按服务商名称覆盖请求超时与重试策略，未覆盖的项使用 dns.timeout 与 retry
+		Providers map[string]ProviderOverride
+		// Fallback 备用服务商，主服务商重试耗尽仍更新失败时改用其发布同一记录，凭证使用对应服务商的配置段
+		Fallback struct {
+			Provider string
+		}
 	}
+	Domain        Domain
 	CheckInterval int
-	Email         Email
+	// StartupDelay 启动后首次检测前固定等待的时间(秒)，给开机时的网络留出分配 IPv6 地址的时间；只使用顶层配置
+	StartupDelay int
+	// StartupJitter 在 StartupDelay 之外再随机等待 0 到该秒数，避免多台设备同时开机时同时请求服务商接口
+	StartupJitter int
+	// CycleTimeout 单轮检测与更新的最长耗时(秒)，超时后取消并计为错误，默认等于 CheckInterval
+	CycleTimeout int
+	// StabilityChecks 新地址需连续检测到的次数，达到后才更新 DNS，用于过滤链路抖动，默认 1(立即更新)
+	StabilityChecks int
+	// AdaptiveInterval 地址稳定时逐步延长检查间隔
+	AdaptiveInterval bool
+	// MinInterval/MaxInterval 自适应间隔的上下限(秒)，MinInterval 默认等于 CheckInterval
+	MinInterval int
+	MaxInterval int
+	// CacheFile 缓存持久化文件路径，为空时不持久化
+	CacheFile string
+	// HistoryFile 地址变更历史文件路径(JSON Lines，仅追加)，为空时不记录
+	HistoryFile string
+	// PidFile 启动时写入进程号的文件路径，正常退出时删除，为空时不写入
+	PidFile string
+	// UserAgent 所有出站 HTTP 请求(服务商接口、HTTP 地址检测、通知)使用的 User-Agent，为空时为 ddns-ipv6/<版本>
+	UserAgent string
+	// DryRun 只记录将要执行的变更，不调用 DNS 接口也不更新缓存
+	DryRun bool
+	// VerifyPropagation 更新后解析记录确认已生效
+	VerifyPropagation bool
+	Verify            Verify
+	// ReconcileOnStart 启动时解析各记录的当前值(使用 Verify.Resolver)并据此设置缓存，只有检测到的地址与实际发布的不同时才更新
+	ReconcileOnStart bool
+	// RemoveRecordOnExit 正常退出时删除本实例发布的记录，记录已被改为其他地址时保留，适用于临时云主机
+	RemoveRecordOnExit bool
+	// EnablePTR 更新 AAAA 记录后将该地址的反向解析(PTR)指向第一个域名，需要反向区域已委派给 dns.provider
+	EnablePTR bool
+	// Hooks 地址变更时更新 DNS 记录前后执行的命令
+	Hooks Hooks
+	// Schedule 静默时段等运行时间安排
+	Schedule Schedule
+	// EnableIPv6 更新 AAAA 记录，默认开启
+	EnableIPv6 bool
+	// EnableIPv4 更新 A 记录
+	EnableIPv4 bool
+	Network    Network
+	Email      Email
+	Telegram   Telegram
+	Webhook    Webhook
+	Bark       Bark
+	DingTalk   DingTalk
+	WeCom      WeCom
+	Discord    Discord
+	Slack      Slack
+	Ntfy       Ntfy
+	Exec       Exec
+	// Notifications 通知渠道选择
+	Notifications Notifications
+	Log           Log
+	Retry         Retry
+	Health        Health
 	Proxy         struct {
-		EnableHTTP      bool
-		HTTPListenAddr  string
-		HTTPTargetAddr  string
+		EnableHTTP     bool
+		HTTPListenAddr string
+		// HTTPTargetAddr 后端地址，可以是单个地址或列表，多个后端时轮询转发
+		HTTPTargetAddr  []string
 		EnableHTTPS     bool
 		HTTPSListenAddr string
-		HTTPSTargetAddr string
+		HTTPSTargetAddr []string
 		CertFile        string
 		KeyFile         string
+		// ACMEEnabled 通过 ACME(Let's Encrypt) 自动申请并续期证书，开启后忽略 CertFile/KeyFile
+		ACMEEnabled bool
+		// ACMEDomains 申请证书的域名
+		ACMEDomains []string
+		// ACMECacheDir 证书缓存目录，默认 acme-cache
+		ACMECacheDir string
+		// ACMEEmail 证书到期等通知的联系邮箱，可为空
+		ACMEEmail string
+		// AddForwardedHeaders 向后端传递 X-Forwarded-For、X-Real-IP 与 X-Forwarded-Proto，默认开启
+		AddForwardedHeaders bool
+		// EnableGzip 客户端接受 gzip 时压缩后端未压缩的响应，已压缩的内容类型(图片、视频、压缩包等)不处理
+		EnableGzip bool
+		// AllowCIDRs 允许访问的客户端网段(IPv4/IPv6 CIDR 或单个地址)，为空时不限制
+		AllowCIDRs []string
+		// DenyCIDRs 拒绝访问的客户端网段，优先于 AllowCIDRs
+		DenyCIDRs []string
+		// MaxConnections 每个监听地址同时打开的客户端连接数上限，0 表示不限制
+		MaxConnections int
+		// ReadTimeout 读取整个请求(含请求头与请求体)的超时(秒)，默认 30，0 表示不限制
+		ReadTimeout int
+		// WriteTimeout 从读完请求头到写完响应的超时(秒)，默认 120，0 表示不限制；WebSocket 等升级连接不受限制
+		WriteTimeout int
+		// IdleTimeout keep-alive 连接等待下一个请求的超时(秒)，默认 120
+		IdleTimeout int
+		// DrainTimeout 重新加载配置替换代理时，旧代理处理完已有请求的最长等待时间(秒)，默认 30
+		DrainTimeout int
+	}
+}
+
+type Tencent struct {
+	SecretId  string
+	SecretKey string
+	// Region 接入地域，默认 ap-guangzhou
+	Region string
+}
+
+type Cloudflare struct {
+	APIToken string
+	ZoneID   string
+}
+
+type Aliyun struct {
+	AccessKeyId     string
+	AccessKeySecret string
+}
+
+type DuckDNS struct {
+	Token string
+	// SubDomain DuckDNS 子域名(xxx.duckdns.org 中的 xxx)，为空时使用 domain 中配置的子域名
+	SubDomain string
+}
+
+type Route53 struct {
+	// AccessKeyId/SecretAccessKey 为空时使用 AWS 默认凭证链
+	AccessKeyId     string
+	SecretAccessKey string
+	HostedZoneID    string
+	// Region SDK 所需的地域，Route 53 为全局服务，默认 us-east-1
+	Region string
+}
+
+type GoDaddy struct {
+	APIKey    string
+	APISecret string
+	// UseOTE 使用 OTE 测试环境接口
+	UseOTE bool
+}
+
+type Namecheap struct {
+	// Password 域名 Advanced DNS 页面中的 Dynamic DNS 密码，域名与主机记录取自 domain 配置
+	Password string
+}
+
+type Huawei struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	// Region 接口所在区域，如 cn-north-4、ap-southeast-1，默认 cn-north-4
+	Region string
+	// ZoneID 公网域名(Zone)的 ID，可在控制台的域名详情中查看
+	ZoneID string
+}
+
+type Domain struct {
+	Domain string
+
+	// SubDomain 单个子域名，保留以兼容旧配置
+	SubDomain string
+	// SubDomains 需要指向同一地址的多个子域名
+	SubDomains []string
+	// TTL 记录缓存时间(秒)，为 0 时使用服务商默认值，超出服务商允许范围时自动调整
+	TTL int
+	// MatchMode 判断 IPv6 地址是否变化的方式: full(默认，比较完整地址) 或 prefix(只比较前缀，忽略接口标识变化)
+	MatchMode string
+	// PrefixLength prefix 模式下比较的前缀长度，默认 64
+	PrefixLength int
+	// CreateIfMissing 记录不存在时自动创建，默认开启；关闭后记录不存在视为错误
+	CreateIfMissing bool
+	// UpdateConcurrency 不支持批量更新的服务商同时更新的记录数，默认 1(逐条更新)
+	UpdateConcurrency int
+}
+
+// Apex 表示主域名本身(如 example.com)的子域名写法
+const Apex = "@"
+
+// AllSubDomains 合并 SubDomain 与 SubDomains 并去重，主域名统一写作 "@"。
+// SubDomains 中的空字符串表示主域名；SubDomain 为空时只在未配置 SubDomains 时表示主域名
+func (d Domain) AllSubDomains() []string {
+	subDomains := d.SubDomains
+	if d.SubDomain != "" || len(subDomains) == 0 {
+		subDomains = append([]string{d.SubDomain}, subDomains...)
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, sub := range subDomains {
+		if sub == "" {
+			sub = Apex
+		}
+		if seen[sub] {
+			continue
+		}
+		seen[sub] = true
+		result = append(result, sub)
+	}
+	return result
+}
+
+// Hostname 返回子域名对应的完整域名，"@" 对应主域名本身
+func (d Domain) Hostname(sub string) string {
+	if sub == "" || sub == Apex {
+		return d.Domain
+	}
+	return sub + "." + d.Domain
+}
+
+type Network struct {
+	// Interface 指定用于检测地址的网卡名，为空时遍历所有网卡
+	Interface string
+	// PreferStableIPv6 优先选择稳定地址，跳过隐私扩展(RFC 4941)生成的临时地址
+	PreferStableIPv6 bool
+	// AddressScope 可选的 IPv6 地址范围: global(默认，公网可路由)、ula(唯一本地地址 fc00::/7) 或 any，链路本地地址始终排除
+	AddressScope string
+	// IPv6Suffix 优先选择以该后缀(接口标识，如 211:32ff:fe12:3456)结尾的地址，仅用于 interface 检测方式
+	IPv6Suffix string
+	// RequireIPv6Suffix 没有匹配 IPv6Suffix 的地址时报错，而不是回退到其他地址
+	RequireIPv6Suffix bool
+	// MinPreferredLifetime 跳过剩余首选生存期不足该秒数的地址(SLAAC 即将废弃的地址)，0 表示不筛选；仅 Linux 支持
+	MinPreferredLifetime int
+	// AllowTunnelAddresses 允许使用 Teredo(2001::/32)、6to4(2002::/16) 与 ISATAP 隧道地址及隧道伪网卡，默认排除
+	AllowTunnelAddresses bool
+	// AllAddresses 将所有符合条件的 IPv6 地址(如多条上行线路的地址)发布为同一名称下的多条 AAAA 记录，
+	// 新增地址时添加记录、地址消失时删除对应记录，仅用于 interface 检测方式
+	AllAddresses bool
+	// DetectionMethods 依次尝试的 IPv6 检测方式: interface(读取本机网卡)、http(请求公网回显服务) 或 upnp(查询本地网关)，
+	// 使用第一个得到可发布地址的方式，默认只使用 interface
+	DetectionMethods []string
+	// DetectionMethod 单个检测方式，保留以兼容旧配置
+	DetectionMethod string
+	// DetectionURLs http 检测方式依次尝试的回显服务
+	DetectionURLs []string
+	// RouterStatusURL upnp 检测方式改为从该路由器状态页中提取 IPv6 地址，为空时通过 UPnP IGD 查询网关
+	RouterStatusURL string
+	// GatewayTimeout upnp 检测方式等待网关响应的超时(秒)，默认 5
+	GatewayTimeout int
+	// ConnectivityHosts 首次 IPv6 检测失败或地址不可路由时，探测连通性所连接的地址(host 或 host:port，默认端口 443)
+	ConnectivityHosts []string
+	// ConnectivityTimeout 连通性检测中单个地址的连接超时(秒)，默认 5
+	ConnectivityTimeout int
+}
+
+// DetectionChain 返回依次尝试的 IPv6 检测方式并去重，配置了 DetectionMethods 时忽略 DetectionMethod
+func (n Network) DetectionChain() []string {
+	methods := n.DetectionMethods
+	if len(methods) == 0 && n.DetectionMethod != "" {
+		methods = []string{n.DetectionMethod}
+	}
+	var result []string
+	for _, method := range methods {
+		if !slices.Contains(result, method) {
+			result = append(result, method)
+		}
+	}
+	if len(result) == 0 {
+		return []string{"interface"}
+	}
+	return result
+}
+
+// ParseIPv6Suffix 解析形如 "211:32ff:fe12:3456" 或 "::1" 的 IPv6Suffix，返回后缀对应的末尾字节
+func (n Network) ParseIPv6Suffix() ([]byte, error) {
+	suffix := n.IPv6Suffix
+	groups := strings.Split(strings.TrimPrefix(suffix, "::"), ":")
+	if len(groups) == 0 || len(groups) > 8 {
+		return nil, fmt.Errorf("invalid IPv6 suffix %q", suffix)
 	}
+
+	result := make([]byte, 0, len(groups)*2)
+	for _, group := range groups {
+		value, err := strconv.ParseUint(group, 16, 16)
+		if err != nil || len(group) > 4 {
+			return nil, fmt.Errorf("invalid IPv6 suffix %q", suffix)
+		}
+		result = append(result, byte(value>>8), byte(value))
+	}
+	return result, nil
+}
+
+// Hostnames 返回所有子域名对应的完整域名
+func (d Domain) Hostnames() []string {
+	var result []string
+	for _, sub := range d.AllSubDomains() {
+		result = append(result, d.Hostname(sub))
+	}
+	return result
+}
+
+// Verify 记录生效校验设置
+type Verify struct {
+	// Resolver 用于校验的 DNS 服务器(如权威服务器 f1g1ns1.dnspod.net)，为空时使用系统解析器
+	Resolver string
+	// Attempts 最多解析次数
+	Attempts int
+	// Delay 每次解析间隔(秒)
+	Delay int
+}
+
+type Log struct {
+	// Format 日志格式: text(默认) 或 json
+	Format string
+	// Level 日志级别，取值同 logrus，默认 info
+	Level string
+	// File 日志文件路径，设置后日志写入该文件而不是标准错误输出
+	File string
+	// MaxSize 单个日志文件的大小上限(MB)，超过后轮转，默认 10
+	MaxSize int
+	// MaxBackups 保留的旧日志文件数，默认 3，为 0 时轮转直接清空文件
+	MaxBackups int
+}
+
+// Retry DNS 更新失败时的重试策略，延迟单位为秒
+type Retry struct {
+	// BaseDelay 首次重试前的等待时间，之后每次翻倍
+	BaseDelay float64
+	// MaxDelay 单次等待时间上限
+	MaxDelay float64
+	// MaxAttempts 最多尝试次数(含首次)
+	MaxAttempts int
+	// Jitter 随机抖动比例(0~1)，实际等待时间在 delay*(1±Jitter) 之间
+	Jitter float64
+}
+
+// ProviderOverride 单个服务商的请求超时与重试策略，字段为 0 时使用全局配置
+type ProviderOverride struct {
+	// Timeout 单个请求超时(秒)
+	Timeout     int
+	MaxAttempts int
+	BaseDelay   float64
+	MaxDelay    float64
+	Jitter      float64
+}
+
+// ProviderName 返回使用的 DNS 服务商名称，未配置时为 tencent
+func (c *Config) ProviderName() string {
+	if c.DNS.Provider == "" {
+		return "tencent"
+	}
+	return c.DNS.Provider
+}
+
+// FallbackConfig 返回以备用服务商替换 dns.provider 后的配置副本，未配置 dns.fallback 时返回 nil
+func (c *Config) FallbackConfig() *Config {
+	if c.DNS.Fallback.Provider == "" {
+		return nil
+	}
+	fallback := *c
+	fallback.DNS.Provider = c.DNS.Fallback.Provider
+	fallback.DNS.Fallback.Provider = ""
+	return &fallback
+}
+
+// ProviderTimeout 返回当前服务商的单个请求超时(秒)
+func (c *Config) ProviderTimeout() int {
+	if override := c.DNS.Providers[c.ProviderName()]; override.Timeout != 0 {
+		return override.Timeout
+	}
+	return c.DNS.Timeout
+}
+
+// ProviderRetry 返回当前服务商的重试策略，即用服务商覆盖项替换后的 retry 配置
+func (c *Config) ProviderRetry() Retry {
+	retry := c.Retry
+	override := c.DNS.Providers[c.ProviderName()]
+	if override.MaxAttempts != 0 {
+		retry.MaxAttempts = override.MaxAttempts
+	}
+	if override.BaseDelay != 0 {
+		retry.BaseDelay = override.BaseDelay
+	}
+	if override.MaxDelay != 0 {
+		retry.MaxDelay = override.MaxDelay
+	}
+	if override.Jitter != 0 {
+		retry.Jitter = override.Jitter
+	}
+	return retry
+}
+
+type Health struct {
+	// ListenAddr 健康检查服务监听地址，为空时不启动
+	ListenAddr string
+	// ErrorThreshold 连续错误达到该次数时发送通知并视为不健康，默认 3
+	ErrorThreshold int
+	// EnableMetrics 在同一端口暴露 Prometheus /metrics
+	EnableMetrics bool
+	// ReloadToken 调用 POST /reload 所需的 Bearer 令牌，为空时不提供该接口
+	ReloadToken string
+	// UpdateToken 调用 POST /update 所需的 Bearer 令牌，为空时不提供该接口
+	UpdateToken string
 }
 
 type Email struct {
@@ -36,22 +439,414 @@ type Email struct {
 	Username   string
 	Password   string
 	Recipient  string
+	// Encryption 加密方式: none(明文，仅可向本机服务器认证)、starttls(通常 587 端口)、tls(隐式 TLS，通常 465 端口)；为空时服务器支持则使用 STARTTLS
+	Encryption string
+	// DialTimeout 连接 SMTP 服务器的超时(秒)，默认 10
+	DialTimeout int
+	// SendTimeout 单封邮件从连接到发送完成的总超时(秒)，默认 30
+	SendTimeout int
 }
 
-func LoadConfig() (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
+type Notifications struct {
+	// Channel 单个通知渠道: email(默认)、telegram、webhook、bark、dingtalk、wecom、discord、slack、ntfy 或 exec
+	Channel string
+	// Channels 同时启用的多个通知渠道
+	Channels []string
+	// NotifyOnChange 地址变更且记录更新成功时发送通知
+	NotifyOnChange bool
+	// NotifyOnStartup 启动后的首次更新也发送变更通知
+	NotifyOnStartup bool
+	// NotifyCooldown 同一类故障通知的最小间隔(秒)，恢复正常后重置
+	NotifyCooldown int
+	// Templates 各类通知的标题与正文模板，适用于所有渠道
+	Templates NotificationTemplates
+}
+
+// NotificationTemplates 各类通知的模板(text/template)，未配置的标题或正文使用默认的中文文案
+type NotificationTemplates struct {
+	// Change 地址变更并更新成功
+	Change MessageTemplate
+	// Recovered 连续错误后恢复正常
+	Recovered MessageTemplate
+	// Connectivity 首次 IPv6 检测时连通性探测失败
+	Connectivity MessageTemplate
+	// DetectFailed/NoPublicIPv6 检测地址失败，后者为检测到的地址均不可路由
+	DetectFailed MessageTemplate
+	NoPublicIPv6 MessageTemplate
+	// UpdateFailed 更新 DNS 记录失败
+	UpdateFailed MessageTemplate
+	// AuthFailed DNS 服务商拒绝凭证
+	AuthFailed MessageTemplate
+	// Test -test-notify 发送的测试通知
+	Test MessageTemplate
+}
+
+// MessageTemplate 一类通知的标题与正文模板
+type MessageTemplate struct {
+	Title string
+	Body  string
+}
 
-	if err := viper.ReadInConfig(); err != nil {
-		log.Fatalf("Error reading config file, %s", err)
+// EnabledChannels 合并 Channel 与 Channels 并去重，均未配置时默认使用邮件
+func (n Notifications) EnabledChannels() []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, channel := range append([]string{n.Channel}, n.Channels...) {
+		if channel == "" || seen[channel] {
+			continue
+		}
+		seen[channel] = true
+		result = append(result, channel)
 	}
+	if len(result) == 0 {
+		result = []string{"email"}
+	}
+	return result
+}
 
-	var cfg Config
-	err := viper.Unmarshal(&cfg)
+type Telegram struct {
+	BotToken string
+	ChatID   string
+}
+
+type Webhook struct {
+	URL string
+	// Template 请求体模板(text/template)，为空时使用默认 JSON 格式
+	Template string
+	// Headers 附加的请求头，如鉴权信息
+	Headers map[string]string
+	// Secret 签名密钥，设置后对每个请求计算 HMAC-SHA256 签名，为空时不签名
+	Secret string
+	// SignatureHeader 携带签名的请求头，默认 X-Signature
+	SignatureHeader string
+	// TimestampHeader 携带签名时间戳(Unix 秒)的请求头，默认 X-Timestamp
+	TimestampHeader string
+}
+
+type Bark struct {
+	// ServerURL Bark 服务地址，自建服务时修改，默认 https://api.day.app
+	ServerURL string
+	DeviceKey string
+	// Sound/Group 可选的提示音与分组
+	Sound string
+	Group string
+}
+
+type DingTalk struct {
+	// WebhookURL 机器人 webhook 地址(含 access_token)
+	WebhookURL string
+	// Secret 安全设置为加签时的密钥，为空时不签名
+	Secret string
+}
+
+type WeCom struct {
+	// WebhookURL 机器人 webhook 地址(含 key)，为空时由 Key 生成
+	WebhookURL string
+	// Key 机器人 webhook 地址中的 key
+	Key string
+	// MsgType 消息类型: text(默认) 或 markdown
+	MsgType string
+	// MentionedMobiles 按手机号 @ 的群成员，"@all" 表示所有人，仅 text 消息支持
+	MentionedMobiles []string
+}
+
+type Discord struct {
+	WebhookURL string
+	// Username/AvatarURL 可选，覆盖 webhook 默认的显示名称与头像
+	Username  string
+	AvatarURL string
+}
+
+type Slack struct {
+	WebhookURL string
+	// UseBlocks 使用 blocks 布局展示域名与新旧地址
+	UseBlocks bool
+}
+
+type Ntfy struct {
+	// ServerURL ntfy 服务地址，默认 https://ntfy.sh
+	ServerURL string
+	Topic     string
+	// Priority 消息优先级: min、low、default、high、urgent 或 1-5
+	Priority string
+	Tags     []string
+	// Token 访问令牌，受保护的主题需要
+	Token string
+}
+
+type Exec struct {
+	// Command 通知时执行的命令，标题与正文追加为最后两个参数，并通过 DDNS_TITLE、DDNS_BODY 等环境变量传递
+	Command string
+	Args    []string
+	// Timeout 命令执行超时(秒)，默认 30
+	Timeout int
+}
+
+// Hooks 地址变更时执行的命令，通过 sh -c(Windows 上为 cmd /C)执行，
+// 新旧地址与域名通过 DDNS_IP、DDNS_OLD_IP、DDNS_HOSTNAME 等环境变量传递
+type Hooks struct {
+	// PreUpdate 更新 DNS 记录前执行，退出码非零或超时时放弃本次更新并计为错误
+	PreUpdate string
+	// PostUpdate 所有记录更新成功后执行，失败只记录日志
+	PostUpdate string
+	// Timeout 单个命令的执行超时(秒)，默认 30
+	Timeout int
+}
+
+// Schedule 运行时间安排
+type Schedule struct {
+	// QuietHours 静默时段，期间继续检测地址，但推迟 DNS 更新并暂停非紧急通知，时段结束后再更新
+	QuietHours QuietHours
+}
+
+// QuietHours 每天的静默时段，End 早于 Start 时跨越午夜(如 23:00 到 07:00)；Start 与 End 均为空时不启用
+type QuietHours struct {
+	// Start/End 开始与结束时间(HH:MM)，包含 Start、不包含 End
+	Start string
+	End   string
+	// Timezone 时段所用的时区(IANA 名称，如 Asia/Shanghai)，为空时使用本机时区
+	Timezone string
+}
+
+// Enabled 判断是否配置了静默时段
+func (q QuietHours) Enabled() bool {
+	return q.Start != "" || q.End != ""
+}
+
+// Until 返回 now 所在静默时段的结束时间，不在静默时段内或未启用时返回 false
+func (q QuietHours) Until(now time.Time) (time.Time, bool) {
+	if !q.Enabled() {
+		return time.Time{}, false
+	}
+	start, startErr := parseClock(q.Start)
+	end, endErr := parseClock(q.End)
+	loc, locErr := q.location()
+	if startErr != nil || endErr != nil || locErr != nil {
+		return time.Time{}, false
+	}
+
+	now = now.In(loc)
+	minute := now.Hour()*60 + now.Minute()
+	var active bool
+	if start < end {
+		active = minute >= start && minute < end
+	} else {
+		active = minute >= start || minute < end
+	}
+	if !active {
+		return time.Time{}, false
+	}
+	until := time.Date(now.Year(), now.Month(), now.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(now) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+// location 返回静默时段所用的时区
+func (q QuietHours) location() (*time.Location, error) {
+	if q.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(q.Timezone)
+}
+
+// parseClock 将 HH:MM 解析为当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
 	if err != nil {
-		log.Fatalf("Unable to decode into struct, %v", err)
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// LoadConfig 读取并解析配置文件，可重复调用以重新加载。
+// targets 中的每一项在顶层配置的基础上覆盖部分配置项，生成独立的更新目标。
+// path 为空时依次查找当前目录下的 config.yaml、config.yml、config.json、config.toml，按扩展名识别 YAML、JSON 或 TOML 格式。
+// 敏感配置项可通过 DDNS_ 前缀的环境变量提供(如 DDNS_TENCENT_SECRET_KEY)，已设置的环境变量优先于配置文件。
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		var err error
+		if path, err = findConfigFile(); err != nil {
+			return nil, err
+		}
+	}
+	format, err := formatOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType(format)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", parseError(path, err))
+	}
+
+	settings := v.AllSettings()
+	rawTargets, _ := settings["targets"].([]any)
+	delete(settings, "targets")
+
+	cfg, err := decode(settings)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode config %s: %w", path, err)
+	}
+	cfg.File = path
+
+	for i, raw := range rawTargets {
+		overrides, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unable to decode config %s: targets[%d] must be a mapping", path, i)
+		}
+		target, err := decode(targetBase(settings, overrides), overrides)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode config %s: targets[%d]: %w", path, i, err)
+		}
+		target.File = path
+		// 继承顶层缓存文件时按目标名称区分，避免多个目标写同一个文件
+		if target.CacheFile != "" && target.CacheFile == cfg.CacheFile {
+			ext := filepath.Ext(target.CacheFile)
+			target.CacheFile = strings.TrimSuffix(target.CacheFile, ext) + "-" + target.Name + ext
+		}
+		cfg.Targets = append(cfg.Targets, target)
+	}
+	return cfg, nil
+}
+
+// UpdateTargets 返回需要独立调度的更新目标，未配置 targets 时返回顶层配置本身
+func (c *Config) UpdateTargets() []*Config {
+	if len(c.Targets) == 0 {
+		return []*Config{c}
+	}
+	return c.Targets
+}
+
+// targetBase 返回目标继承的顶层配置。各配置段按字段合并，只有 domain 整段替换，
+// 以免目标继承顶层的子域名列表
+func targetBase(settings, overrides map[string]any) map[string]any {
+	for key := range overrides {
+		if strings.EqualFold(key, "domain") {
+			base := make(map[string]any, len(settings))
+			for k, v := range settings {
+				if k != "domain" {
+					base[k] = v
+				}
+			}
+			return base
+		}
+	}
+	return settings
+}
+
+// decode 依次合并各层配置并补充默认值与环境变量，后面的层覆盖前面的层
+func decode(layers ...map[string]any) (*Config, error) {
+	v := viper.New()
+	v.SetDefault("enableIPv6", true)
+	v.SetDefault("dns.timeout", 30)
+	v.SetDefault("tencent.region", "ap-guangzhou")
+	v.SetDefault("huawei.region", "cn-north-4")
+	v.SetDefault("route53.region", "us-east-1")
+	v.SetDefault("domain.prefixLength", 64)
+	v.SetDefault("domain.createIfMissing", true)
+	v.SetDefault("domain.updateConcurrency", 1)
+	v.SetDefault("stabilityChecks", 1)
+	v.SetDefault("network.addressScope", "global")
+	v.SetDefault("network.connectivityTimeout", 5)
+	v.SetDefault("network.gatewayTimeout", 5)
+	v.SetDefault("email.dialTimeout", 10)
+	v.SetDefault("email.sendTimeout", 30)
+	v.SetDefault("webhook.signatureHeader", "X-Signature")
+	v.SetDefault("webhook.timestampHeader", "X-Timestamp")
+	v.SetDefault("bark.serverURL", "https://api.day.app")
+	v.SetDefault("ntfy.serverURL", "https://ntfy.sh")
+	v.SetDefault("wecom.msgType", "text")
+	v.SetDefault("exec.timeout", 30)
+	v.SetDefault("hooks.timeout", 30)
+	v.SetDefault("health.errorThreshold", 3)
+	v.SetDefault("verify.attempts", 5)
+	v.SetDefault("verify.delay", 3)
+	v.SetDefault("log.maxSize", 10)
+	v.SetDefault("log.maxBackups", 3)
+	v.SetDefault("retry.baseDelay", 1)
+	v.SetDefault("retry.maxDelay", 60)
+	v.SetDefault("retry.maxAttempts", 5)
+	v.SetDefault("retry.jitter", 0.5)
+	v.SetDefault("proxy.addForwardedHeaders", true)
+	v.SetDefault("proxy.acmeCacheDir", "acme-cache")
+	v.SetDefault("proxy.readTimeout", 30)
+	v.SetDefault("proxy.writeTimeout", 120)
+	v.SetDefault("proxy.idleTimeout", 120)
+	v.SetDefault("proxy.drainTimeout", 30)
+	for _, layer := range layers {
+		// 合并时会直接引用并修改嵌套的 map，先复制以免影响其他目标
+		if err := v.MergeConfigMap(copyMap(layer)); err != nil {
+			return nil, err
+		}
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	cfg.applyEnv()
+	sort.Strings(cfg.EnvOverrides)
+	if cfg.MinInterval == 0 {
+		cfg.MinInterval = cfg.CheckInterval
+	}
+	if cfg.CycleTimeout == 0 {
+		cfg.CycleTimeout = cfg.CheckInterval
 	}
 
 	return &cfg, nil
 }
+
+func copyMap(m map[string]any) map[string]any {
+	result := make(map[string]any, len(m))
+	for k, v := range m {
+		if nested, ok := v.(map[string]any); ok {
+			v = copyMap(nested)
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// Changed 返回新旧配置间有变化的顶层配置项，如 domain、checkInterval
+func Changed(old, new *Config) []string {
+	var fields []string
+	oldValue, newValue := reflect.ValueOf(*old), reflect.ValueOf(*new)
+	for i := 0; i < oldValue.NumField(); i++ {
+		field := oldValue.Type().Field(i)
+		switch field.Name {
+		case "File", "EnvOverrides", "Targets":
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			fields = append(fields, configKey(field.Name))
+		}
+	}
+	return fields
+}
+
+// configKey 将字段名转换为配置文件中的写法，如 CheckInterval -> checkInterval、DNS -> dns
+func configKey(name string) string {
+	if strings.ToUpper(name) == name {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// RestartRequired 返回新旧配置间无法在运行时生效的变更项
+func RestartRequired(old, new *Config) []string {
+	var fields []string
+	if old.Health.ListenAddr != new.Health.ListenAddr || old.Health.EnableMetrics != new.Health.EnableMetrics {
+		fields = append(fields, "health.listenAddr/enableMetrics")
+	}
+	if old.CacheFile != new.CacheFile {
+		fields = append(fields, "cacheFile")
+	}
+	if old.PidFile != new.PidFile {
+		fields = append(fields, "pidFile")
+	}
+	return fields
+}
@@ -0,0 +1,40 @@
+package config
+
+import "os"
+
+// envOverrides 返回可通过环境变量提供的敏感配置项
+func (c *Config) envOverrides() map[string]*string {
+	return map[string]*string{
+		"DDNS_TENCENT_SECRET_ID":         &c.Tencent.SecretId,
+		"DDNS_TENCENT_SECRET_KEY":        &c.Tencent.SecretKey,
+		"DDNS_CLOUDFLARE_API_TOKEN":      &c.Cloudflare.APIToken,
+		"DDNS_ALIYUN_ACCESS_KEY_ID":      &c.Aliyun.AccessKeyId,
+		"DDNS_ALIYUN_ACCESS_KEY_SECRET":  &c.Aliyun.AccessKeySecret,
+		"DDNS_DUCKDNS_TOKEN":             &c.DuckDNS.Token,
+		"DDNS_ROUTE53_ACCESS_KEY_ID":     &c.Route53.AccessKeyId,
+		"DDNS_ROUTE53_SECRET_ACCESS_KEY": &c.Route53.SecretAccessKey,
+		"DDNS_GODADDY_API_KEY":           &c.GoDaddy.APIKey,
+		"DDNS_GODADDY_API_SECRET":        &c.GoDaddy.APISecret,
+		"DDNS_HUAWEI_ACCESS_KEY_ID":      &c.Huawei.AccessKeyId,
+		"DDNS_HUAWEI_SECRET_ACCESS_KEY":  &c.Huawei.SecretAccessKey,
+		"DDNS_NAMECHEAP_PASSWORD":        &c.Namecheap.Password,
+		"DDNS_EMAIL_PASSWORD":            &c.Email.Password,
+		"DDNS_DINGTALK_SECRET":           &c.DingTalk.Secret,
+		"DDNS_WECOM_KEY":                 &c.WeCom.Key,
+		"DDNS_WEBHOOK_SECRET":            &c.Webhook.Secret,
+		"DDNS_TELEGRAM_BOT_TOKEN":        &c.Telegram.BotToken,
+		"DDNS_HEALTH_RELOAD_TOKEN":       &c.Health.ReloadToken,
+		"DDNS_HEALTH_UPDATE_TOKEN":       &c.Health.UpdateToken,
+		"DDNS_NTFY_TOKEN":                &c.Ntfy.Token,
+	}
+}
+
+// applyEnv 用非空的环境变量覆盖配置文件中的值，并记录被覆盖的变量名
+func (c *Config) applyEnv() {
+	for name, field := range c.envOverrides() {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			*field = value
+			c.EnvOverrides = append(c.EnvOverrides, name)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// configFormats 支持的配置文件扩展名与格式，未指定路径时按此顺序在当前目录查找 config.<ext>。
+// 各格式使用相同的字段名(如 checkInterval、tencent.secretId)，字段名不区分大小写。
+var configFormats = []struct {
+	ext    string
+	format string
+}{
+	{".yaml", "yaml"},
+	{".yml", "yaml"},
+	{".json", "json"},
+	{".toml", "toml"},
+}
+
+// formatOf 根据扩展名返回配置格式
+func formatOf(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range configFormats {
+		if f.ext == ext {
+			return f.format, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported config file extension %q in %s, use .yaml, .yml, .json or .toml", ext, path)
+}
+
+// findConfigFile 在当前目录查找默认配置文件
+func findConfigFile() (string, error) {
+	for _, f := range configFormats {
+		path := "config" + f.ext
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no config file found, expected config.yaml, config.yml, config.json or config.toml in the current directory")
+}
+
+// parseError 为解析错误补充文件名与出错位置
+func parseError(path string, err error) error {
+	var tomlErr *toml.DecodeError
+	if errors.As(err, &tomlErr) {
+		row, col := tomlErr.Position()
+		return fmt.Errorf("%s:%d:%d: %w", path, row, col, err)
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		if data, readErr := os.ReadFile(path); readErr == nil && syntaxErr.Offset <= int64(len(data)) {
+			line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+	}
+
+	// YAML 的错误信息中已包含行号
+	return fmt.Errorf("%s: %w", path, err)
+}
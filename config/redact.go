@@ -0,0 +1,42 @@
+package config
+
+// redacted 替换敏感配置项后的占位符
+const redacted = "***"
+
+// Redacted 返回隐藏了密钥、密码、令牌等敏感信息的配置副本，用于打印或排查问题
+func (c *Config) Redacted() *Config {
+	r := *c
+	for _, field := range r.envOverrides() {
+		redact(field)
+	}
+
+	// 以下地址或请求头本身包含访问凭证
+	for _, field := range []*string{
+		&r.Bark.DeviceKey,
+		&r.DingTalk.WebhookURL,
+		&r.WeCom.WebhookURL,
+		&r.Discord.WebhookURL,
+		&r.Slack.WebhookURL,
+	} {
+		redact(field)
+	}
+	if len(c.Webhook.Headers) > 0 {
+		r.Webhook.Headers = make(map[string]string, len(c.Webhook.Headers))
+		for key := range c.Webhook.Headers {
+			r.Webhook.Headers[key] = redacted
+		}
+	}
+	if len(c.Targets) > 0 {
+		r.Targets = make([]*Config, len(c.Targets))
+		for i, target := range c.Targets {
+			r.Targets[i] = target.Redacted()
+		}
+	}
+	return &r
+}
+
+func redact(field *string) {
+	if *field != "" {
+		*field = redacted
+	}
+}
@@ -0,0 +1,428 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Validate 按已启用的功能检查必填项，错误信息中给出对应的配置字段。
+// 配置了 targets 时逐个检查各目标，顶层配置只作为继承的默认值。
+func (c *Config) Validate() error {
+	if c.StartupDelay < 0 || c.StartupJitter < 0 {
+		return fmt.Errorf("startupDelay and startupJitter must not be negative, got %d and %d", c.StartupDelay, c.StartupJitter)
+	}
+	if len(c.Targets) == 0 {
+		if err := c.validateTarget(); err != nil {
+			return err
+		}
+		return c.validateProxy()
+	}
+
+	names := make(map[string]bool)
+	for i, target := range c.Targets {
+		if target.Name == "" {
+			return fmt.Errorf("targets[%d].name is required", i)
+		}
+		if names[target.Name] {
+			return fmt.Errorf("targets[%d].name %q is used by another target", i, target.Name)
+		}
+		names[target.Name] = true
+		if err := target.validateTarget(); err != nil {
+			return fmt.Errorf("targets[%d] (%s): %w", i, target.Name, err)
+		}
+	}
+	return c.validateProxy()
+}
+
+// validateTarget 检查单个更新目标的域名、检测、服务商与通知配置
+func (c *Config) validateTarget() error {
+	if c.Domain.Domain == "" {
+		return fmt.Errorf("domain.domain is required")
+	}
+	if c.Domain.TTL < 0 {
+		return fmt.Errorf("domain.ttl must not be negative, got %d", c.Domain.TTL)
+	}
+	if c.Domain.UpdateConcurrency < 1 {
+		return fmt.Errorf("domain.updateConcurrency must be at least 1, got %d", c.Domain.UpdateConcurrency)
+	}
+	if c.CycleTimeout < 0 {
+		return fmt.Errorf("cycleTimeout must not be negative, got %d", c.CycleTimeout)
+	}
+	switch c.Domain.MatchMode {
+	case "", "full":
+	case "prefix":
+		if c.Domain.PrefixLength < 1 || c.Domain.PrefixLength > 128 {
+			return fmt.Errorf("domain.prefixLength must be between 1 and 128, got %d", c.Domain.PrefixLength)
+		}
+	default:
+		return fmt.Errorf("domain.matchMode must be full or prefix, got %q", c.Domain.MatchMode)
+	}
+	if c.CheckInterval <= 0 {
+		return fmt.Errorf("checkInterval must be positive, got %d", c.CheckInterval)
+	}
+	if c.StabilityChecks < 1 {
+		return fmt.Errorf("stabilityChecks must be at least 1, got %d", c.StabilityChecks)
+	}
+	if c.AdaptiveInterval && (c.MinInterval <= 0 || c.MaxInterval < c.MinInterval) {
+		return fmt.Errorf("maxInterval must be at least minInterval (%d) when adaptiveInterval is enabled, got %d", c.MinInterval, c.MaxInterval)
+	}
+	if !c.EnableIPv6 && !c.EnableIPv4 {
+		return fmt.Errorf("at least one of enableIPv6 and enableIPv4 must be true")
+	}
+
+	switch c.Network.AddressScope {
+	case "", "global", "ula", "any":
+	default:
+		return fmt.Errorf("network.addressScope must be global, ula or any, got %q", c.Network.AddressScope)
+	}
+	if len(c.Network.DetectionMethods) > 0 && c.Network.DetectionMethod != "" {
+		return fmt.Errorf("network.detectionMethod cannot be combined with network.detectionMethods, list all methods in network.detectionMethods")
+	}
+	chain := c.Network.DetectionChain()
+	for _, method := range chain {
+		switch method {
+		case "interface", "http":
+		case "upnp":
+			if c.Network.GatewayTimeout <= 0 {
+				return fmt.Errorf("network.gatewayTimeout must be positive, got %d", c.Network.GatewayTimeout)
+			}
+		default:
+			return fmt.Errorf("network.detectionMethods must only contain interface, http or upnp, got %q", method)
+		}
+	}
+	if c.Network.IPv6Suffix != "" {
+		if _, err := c.Network.ParseIPv6Suffix(); err != nil {
+			return fmt.Errorf("network.ipv6Suffix: %v", err)
+		}
+	}
+	if c.Network.MinPreferredLifetime < 0 {
+		return fmt.Errorf("network.minPreferredLifetime must not be negative, got %d", c.Network.MinPreferredLifetime)
+	}
+	if c.Network.AllAddresses {
+		if len(chain) != 1 || chain[0] != "interface" {
+			return fmt.Errorf("network.allAddresses requires network.detectionMethods to be [interface], got %v", chain)
+		}
+		if c.Domain.MatchMode == "prefix" {
+			return fmt.Errorf("network.allAddresses cannot be combined with domain.matchMode prefix")
+		}
+		if !slices.Contains(multiValueProviders, c.ProviderName()) {
+			return fmt.Errorf("network.allAddresses is not supported by dns.provider %s, use one of %s", c.ProviderName(), strings.Join(multiValueProviders, ", "))
+		}
+	}
+	if c.RemoveRecordOnExit && !slices.Contains(deleteProviders, c.ProviderName()) {
+		return fmt.Errorf("removeRecordOnExit is not supported by dns.provider %s, use one of %s", c.ProviderName(), strings.Join(deleteProviders, ", "))
+	}
+	if c.EnablePTR {
+		if !c.EnableIPv6 {
+			return fmt.Errorf("enablePTR requires enableIPv6")
+		}
+		if !slices.Contains(ptrProviders, c.ProviderName()) {
+			return fmt.Errorf("enablePTR is not supported by dns.provider %s, use one of %s", c.ProviderName(), strings.Join(ptrProviders, ", "))
+		}
+	}
+	if (c.Hooks.PreUpdate != "" || c.Hooks.PostUpdate != "") && c.Hooks.Timeout <= 0 {
+		return fmt.Errorf("hooks.timeout must be positive, got %d", c.Hooks.Timeout)
+	}
+	if quiet := c.Schedule.QuietHours; quiet.Enabled() {
+		start, err := parseClock(quiet.Start)
+		if err != nil {
+			return fmt.Errorf("schedule.quietHours.start: %v", err)
+		}
+		end, err := parseClock(quiet.End)
+		if err != nil {
+			return fmt.Errorf("schedule.quietHours.end: %v", err)
+		}
+		if start == end {
+			return fmt.Errorf("schedule.quietHours.start and schedule.quietHours.end must differ, got %s", quiet.Start)
+		}
+		if _, err := quiet.location(); err != nil {
+			return fmt.Errorf("schedule.quietHours.timezone: %v", err)
+		}
+	}
+	if c.EnableIPv6 && c.Network.ConnectivityTimeout <= 0 {
+		return fmt.Errorf("network.connectivityTimeout must be positive, got %d", c.Network.ConnectivityTimeout)
+	}
+	if c.Notifications.NotifyCooldown < 0 {
+		return fmt.Errorf("notifications.notifyCooldown must not be negative, got %d", c.Notifications.NotifyCooldown)
+	}
+
+	if c.VerifyPropagation && c.Verify.Attempts < 1 {
+		return fmt.Errorf("verify.attempts must be at least 1 when verifyPropagation is enabled, got %d", c.Verify.Attempts)
+	}
+
+	switch c.Log.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("log.format must be text or json, got %q", c.Log.Format)
+	}
+	if c.Log.Level != "" {
+		if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+			return fmt.Errorf("log.level: %v", err)
+		}
+	}
+	if c.Log.File != "" && (c.Log.MaxSize <= 0 || c.Log.MaxBackups < 0) {
+		return fmt.Errorf("log.maxSize must be positive and log.maxBackups must not be negative when log.file is set")
+	}
+
+	if err := validateRetry("retry", c.Retry); err != nil {
+		return err
+	}
+	if c.DNS.Timeout <= 0 {
+		return fmt.Errorf("dns.timeout must be positive, got %d", c.DNS.Timeout)
+	}
+	for name, override := range c.DNS.Providers {
+		if !slices.Contains(providers, name) {
+			return fmt.Errorf("dns.providers: unknown provider %q", name)
+		}
+		if override.Timeout < 0 {
+			return fmt.Errorf("dns.providers.%s.timeout must not be negative, got %d", name, override.Timeout)
+		}
+	}
+	if err := c.validateProvider(); err != nil {
+		return err
+	}
+	if fallback := c.FallbackConfig(); fallback != nil {
+		if !slices.Contains(providers, fallback.DNS.Provider) {
+			return fmt.Errorf("dns.fallback.provider %q is not supported", fallback.DNS.Provider)
+		}
+		if fallback.DNS.Provider == c.ProviderName() {
+			return fmt.Errorf("dns.fallback.provider must differ from dns.provider %s", c.ProviderName())
+		}
+		if c.Network.AllAddresses && !slices.Contains(multiValueProviders, fallback.DNS.Provider) {
+			return fmt.Errorf("network.allAddresses is not supported by dns.fallback.provider %s, use one of %s", fallback.DNS.Provider, strings.Join(multiValueProviders, ", "))
+		}
+		if err := fallback.validateProvider(); err != nil {
+			return fmt.Errorf("dns.fallback: %w", err)
+		}
+	}
+
+	for _, channel := range c.Notifications.EnabledChannels() {
+		switch channel {
+		case "email":
+			if c.Email.DialTimeout <= 0 || c.Email.SendTimeout <= 0 {
+				return fmt.Errorf("email.dialTimeout and email.sendTimeout must be positive")
+			}
+			switch c.Email.Encryption {
+			case "", "none", "starttls", "tls":
+			default:
+				return fmt.Errorf("email.encryption must be none, starttls or tls, got %q", c.Email.Encryption)
+			}
+		case "telegram":
+			if c.Telegram.BotToken == "" || c.Telegram.ChatID == "" {
+				return fmt.Errorf("telegram.botToken and telegram.chatId are required when the telegram channel is enabled")
+			}
+		case "webhook":
+			if c.Webhook.URL == "" {
+				return fmt.Errorf("webhook.url is required when the webhook channel is enabled")
+			}
+			if c.Webhook.Secret != "" && (c.Webhook.SignatureHeader == "" || c.Webhook.TimestampHeader == "") {
+				return fmt.Errorf("webhook.signatureHeader and webhook.timestampHeader must not be empty when webhook.secret is set")
+			}
+		case "bark":
+			if c.Bark.DeviceKey == "" {
+				return fmt.Errorf("bark.deviceKey is required when the bark channel is enabled")
+			}
+		case "dingtalk":
+			if c.DingTalk.WebhookURL == "" {
+				return fmt.Errorf("dingtalk.webhookURL is required when the dingtalk channel is enabled")
+			}
+		case "wecom":
+			if c.WeCom.WebhookURL == "" && c.WeCom.Key == "" {
+				return fmt.Errorf("wecom.webhookURL or wecom.key is required when the wecom channel is enabled")
+			}
+			if c.WeCom.MsgType != "text" && c.WeCom.MsgType != "markdown" {
+				return fmt.Errorf("wecom.msgType must be text or markdown, got %q", c.WeCom.MsgType)
+			}
+			if c.WeCom.MsgType == "markdown" && len(c.WeCom.MentionedMobiles) > 0 {
+				return fmt.Errorf("wecom.mentionedMobiles is only supported with wecom.msgType text")
+			}
+		case "discord":
+			if c.Discord.WebhookURL == "" {
+				return fmt.Errorf("discord.webhookURL is required when the discord channel is enabled")
+			}
+		case "slack":
+			if c.Slack.WebhookURL == "" {
+				return fmt.Errorf("slack.webhookURL is required when the slack channel is enabled")
+			}
+		case "ntfy":
+			if c.Ntfy.Topic == "" {
+				return fmt.Errorf("ntfy.topic is required when the ntfy channel is enabled")
+			}
+		case "exec":
+			if c.Exec.Command == "" {
+				return fmt.Errorf("exec.command is required when the exec channel is enabled")
+			}
+			if c.Exec.Timeout <= 0 {
+				return fmt.Errorf("exec.timeout must be positive, got %d", c.Exec.Timeout)
+			}
+		default:
+			return fmt.Errorf("notifications channel %q is not supported", channel)
+		}
+	}
+	return nil
+}
+
+// providers 支持的 DNS 服务商
+var providers = []string{"tencent", "cloudflare", "aliyun", "duckdns", "route53", "godaddy", "namecheap", "huawei"}
+
+// deleteProviders 支持删除记录(removeRecordOnExit)的服务商
+var deleteProviders = []string{"tencent", "cloudflare", "aliyun", "route53", "godaddy", "huawei"}
+
+// ptrProviders 支持发布反向解析记录(enablePTR)的服务商
+var ptrProviders = []string{"tencent", "cloudflare"}
+
+// multiValueProviders 支持在同一名称下发布多条 AAAA 记录(network.allAddresses)的服务商
+var multiValueProviders = []string{"cloudflare", "route53", "godaddy", "huawei"}
+
+// validateProvider 检查 dns.provider 对应服务商的凭证与重试配置
+func (c *Config) validateProvider() error {
+	if _, ok := c.DNS.Providers[c.ProviderName()]; ok {
+		if err := validateRetry("dns.providers."+c.ProviderName(), c.ProviderRetry()); err != nil {
+			return err
+		}
+	}
+	switch c.DNS.Provider {
+	case "", "tencent":
+		if c.Tencent.SecretId == "" {
+			return fmt.Errorf("tencent.secretId is required when dns.provider is tencent")
+		}
+		if c.Tencent.SecretKey == "" {
+			return fmt.Errorf("tencent.secretKey is required when dns.provider is tencent")
+		}
+		if c.Tencent.Region == "" {
+			return fmt.Errorf("tencent.region must not be empty")
+		}
+	case "cloudflare":
+		if c.Cloudflare.APIToken == "" {
+			return fmt.Errorf("cloudflare.apiToken is required when dns.provider is cloudflare")
+		}
+		if c.Cloudflare.ZoneID == "" {
+			return fmt.Errorf("cloudflare.zoneId is required when dns.provider is cloudflare")
+		}
+	case "aliyun":
+		if c.Aliyun.AccessKeyId == "" || c.Aliyun.AccessKeySecret == "" {
+			return fmt.Errorf("aliyun.accessKeyId and aliyun.accessKeySecret are required when dns.provider is aliyun")
+		}
+	case "duckdns":
+		if c.DuckDNS.Token == "" {
+			return fmt.Errorf("duckdns.token is required when dns.provider is duckdns")
+		}
+		if c.DuckDNS.SubDomain == "" && slices.Contains(c.Domain.AllSubDomains(), Apex) {
+			return fmt.Errorf("duckdns.subDomain is required when updating the apex (@) with dns.provider duckdns")
+		}
+	case "route53":
+		if c.Route53.HostedZoneID == "" {
+			return fmt.Errorf("route53.hostedZoneId is required when dns.provider is route53")
+		}
+		if (c.Route53.AccessKeyId == "") != (c.Route53.SecretAccessKey == "") {
+			return fmt.Errorf("route53.accessKeyId and route53.secretAccessKey must be set together")
+		}
+	case "godaddy":
+		if c.GoDaddy.APIKey == "" || c.GoDaddy.APISecret == "" {
+			return fmt.Errorf("godaddy.apiKey and godaddy.apiSecret are required when dns.provider is godaddy")
+		}
+	case "namecheap":
+		if c.Namecheap.Password == "" {
+			return fmt.Errorf("namecheap.password is required when dns.provider is namecheap")
+		}
+	case "huawei":
+		if c.Huawei.AccessKeyId == "" || c.Huawei.SecretAccessKey == "" {
+			return fmt.Errorf("huawei.accessKeyId and huawei.secretAccessKey are required when dns.provider is huawei")
+		}
+		if c.Huawei.ZoneID == "" {
+			return fmt.Errorf("huawei.zoneId is required when dns.provider is huawei")
+		}
+		if c.Huawei.Region == "" {
+			return fmt.Errorf("huawei.region must not be empty")
+		}
+	default:
+		return fmt.Errorf("dns.provider %q is not supported", c.DNS.Provider)
+	}
+	return nil
+}
+
+// validateRetry 检查重试策略，field 为错误信息中的配置前缀
+func validateRetry(field string, retry Retry) error {
+	if retry.MaxAttempts < 1 {
+		return fmt.Errorf("%s.maxAttempts must be at least 1, got %d", field, retry.MaxAttempts)
+	}
+	if retry.BaseDelay < 0 || retry.MaxDelay < retry.BaseDelay {
+		return fmt.Errorf("%s.baseDelay must be non-negative and not exceed %s.maxDelay", field, field)
+	}
+	if retry.Jitter < 0 || retry.Jitter > 1 {
+		return fmt.Errorf("%s.jitter must be between 0 and 1, got %v", field, retry.Jitter)
+	}
+	return nil
+}
+
+// validateProxy 检查反向代理配置
+func (c *Config) validateProxy() error {
+	if c.Proxy.EnableHTTP && len(c.Proxy.HTTPTargetAddr) == 0 {
+		return fmt.Errorf("proxy.httpTargetAddr is required when proxy.enableHTTP is true")
+	}
+	if c.Proxy.EnableHTTPS {
+		if len(c.Proxy.HTTPSTargetAddr) == 0 {
+			return fmt.Errorf("proxy.httpsTargetAddr is required when proxy.enableHTTPS is true")
+		}
+		if err := c.validateTLS(); err != nil {
+			return err
+		}
+	}
+	if c.Proxy.MaxConnections < 0 {
+		return fmt.Errorf("proxy.maxConnections must not be negative, got %d", c.Proxy.MaxConnections)
+	}
+	if c.Proxy.ReadTimeout < 0 || c.Proxy.WriteTimeout < 0 || c.Proxy.IdleTimeout < 0 {
+		return fmt.Errorf("proxy.readTimeout, proxy.writeTimeout and proxy.idleTimeout must not be negative")
+	}
+	if c.Proxy.DrainTimeout < 0 {
+		return fmt.Errorf("proxy.drainTimeout must not be negative, got %d", c.Proxy.DrainTimeout)
+	}
+	if err := validateCIDRs("proxy.allowCIDRs", c.Proxy.AllowCIDRs); err != nil {
+		return err
+	}
+	return validateCIDRs("proxy.denyCIDRs", c.Proxy.DenyCIDRs)
+}
+
+// validateTLS 检查 HTTPS 代理的证书来源，开启 ACME 时无需证书文件
+func (c *Config) validateTLS() error {
+	if c.Proxy.ACMEEnabled {
+		if len(c.Proxy.ACMEDomains) == 0 {
+			return fmt.Errorf("proxy.acmeDomains is required when proxy.acmeEnabled is true")
+		}
+		return nil
+	}
+	if err := fileExists("proxy.certFile", c.Proxy.CertFile); err != nil {
+		return err
+	}
+	return fileExists("proxy.keyFile", c.Proxy.KeyFile)
+}
+
+// validateCIDRs 检查每一项是 CIDR 或单个 IP 地址
+func validateCIDRs(field string, values []string) error {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if strings.Contains(value, "/") {
+			if _, err := netip.ParsePrefix(value); err != nil {
+				return fmt.Errorf("%s: invalid CIDR %q", field, value)
+			}
+		} else if _, err := netip.ParseAddr(value); err != nil {
+			return fmt.Errorf("%s: invalid address %q", field, value)
+		}
+	}
+	return nil
+}
+
+func fileExists(field, path string) error {
+	if path == "" {
+		return fmt.Errorf("%s is required when proxy.enableHTTPS is true", field)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s: %v", field, err)
+	}
+	return nil
+}
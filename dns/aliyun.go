@@ -0,0 +1,165 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"ddns-ipv6/config"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/alidns"
+)
+
+// aliyunRegion 云解析为全局服务，使用默认地域即可
+const aliyunRegion = "cn-hangzhou"
+
+// 云解析允许的 TTL 范围，实际下限取决于版本(免费版为 600)
+const (
+	aliyunMinTTL = 1
+	aliyunMaxTTL = 86400
+)
+
+// AliyunProvider 阿里云云解析 DNS
+type AliyunProvider struct {
+	client *alidns.Client
+}
+
+func NewAliyunProvider(cfg config.Aliyun, httpClient *http.Client) (*AliyunProvider, error) {
+	client, err := alidns.NewClientWithAccessKey(aliyunRegion, cfg.AccessKeyId, cfg.AccessKeySecret)
+	if err != nil {
+		return nil, err
+	}
+	// SDK 每次请求都会改写 *http.Transport 的拨号函数，包装后才能保留自定义解析器
+	client.SetTransport(opaqueTransport{httpClient.Transport})
+	if httpClient.Timeout > 0 {
+		client.SetReadTimeout(httpClient.Timeout)
+	}
+	return &AliyunProvider{client: client}, nil
+}
+
+// UpdateRecord 更新域名解析记录，记录不存在且开启 CreateIfMissing 时创建。
+// SDK 不支持 context，只在每次调用接口前检查 ctx 是否已取消。
+func (p *AliyunProvider) UpdateRecord(ctx context.Context, record Record) error {
+	return aliyunError(p.updateRecord(ctx, record))
+}
+
+func (p *AliyunProvider) updateRecord(ctx context.Context, record Record) error {
+	ttl := clampTTL("aliyun", record.TTL, aliyunMinTTL, aliyunMaxTTL)
+
+	// 查询子域名下的记录
+	existing, err := p.find(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		if !record.CreateIfMissing {
+			return recordNotFound(record)
+		}
+		addRequest := alidns.CreateAddDomainRecordRequest()
+		addRequest.DomainName = record.Domain
+		addRequest.RR = record.SubDomain
+		addRequest.Type = record.Type
+		addRequest.Value = record.Value
+		if ttl > 0 {
+			addRequest.TTL = requests.NewInteger(ttl)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := p.client.AddDomainRecord(addRequest); err != nil {
+			return err
+		}
+		logBootstrapped(record)
+		return nil
+	}
+
+	if existing.Value == record.Value && (ttl == 0 || existing.TTL == int64(ttl)) {
+		return nil
+	}
+
+	// 更新记录
+	updateRequest := alidns.CreateUpdateDomainRecordRequest()
+	updateRequest.RecordId = existing.RecordId
+	updateRequest.RR = record.SubDomain
+	updateRequest.Type = record.Type
+	updateRequest.Value = record.Value
+	if ttl > 0 {
+		updateRequest.TTL = requests.NewInteger(ttl)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err = p.client.UpdateDomainRecord(updateRequest)
+	// 记录值未变化时接口返回 DomainRecordDuplicate，视为成功
+	var serverErr *sdkerrors.ServerError
+	if errors.As(err, &serverErr) && serverErr.ErrorCode() == "DomainRecordDuplicate" {
+		return nil
+	}
+	return err
+}
+
+// CheckRecord 查询记录是否存在
+func (p *AliyunProvider) CheckRecord(ctx context.Context, record Record) (bool, error) {
+	existing, err := p.find(ctx, record)
+	return existing != nil, aliyunError(err)
+}
+
+// DeleteRecord 删除记录，记录的当前值不是 record.Value 时不删除
+func (p *AliyunProvider) DeleteRecord(ctx context.Context, record Record) error {
+	existing, err := p.find(ctx, record)
+	if err != nil || existing == nil {
+		return aliyunError(err)
+	}
+	if !sameValues(record, []string{existing.Value}) {
+		return recordChanged(record, []string{existing.Value})
+	}
+
+	request := alidns.CreateDeleteDomainRecordRequest()
+	request.RecordId = existing.RecordId
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err = p.client.DeleteDomainRecord(request)
+	return aliyunError(err)
+}
+
+// find 查询子域名下指定类型的记录，不存在时返回 nil
+func (p *AliyunProvider) find(ctx context.Context, record Record) (*alidns.Record, error) {
+	listRequest := alidns.CreateDescribeSubDomainRecordsRequest()
+	listRequest.DomainName = record.Domain
+	listRequest.SubDomain = record.Name()
+	listRequest.Type = record.Type
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	listResponse, err := p.client.DescribeSubDomainRecords(listRequest)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range listResponse.DomainRecords.Record {
+		if r.Type == record.Type && r.RR == record.SubDomain {
+			return &listResponse.DomainRecords.Record[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// aliyunError 将 AccessKey 不存在、已禁用、签名错误或 RAM 未授权等错误标记为凭证错误
+func aliyunError(err error) error {
+	var serverErr *sdkerrors.ServerError
+	if !errors.As(err, &serverErr) || IsAuthError(err) {
+		return err
+	}
+	code := serverErr.ErrorCode()
+	if isAuthStatus(serverErr.HttpStatus()) || strings.HasPrefix(code, "InvalidAccessKeyId") ||
+		code == "SignatureDoesNotMatch" || strings.HasPrefix(code, "Forbidden") {
+		return authError(err)
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// AuthError 服务商拒绝了配置的凭证或凭证权限不足，重试无法解决
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("credentials rejected: %v", e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// IsAuthError 判断错误是否由凭证无效或权限不足引起
+func IsAuthError(err error) bool {
+	var authErr *AuthError
+	return errors.As(err, &authErr)
+}
+
+// authError 将 err 标记为凭证错误，并停止重试
+func authError(err error) error {
+	return backoff.Permanent(&AuthError{Err: err})
+}
+
+// isAuthStatus 判断 HTTP 状态码是否表示认证失败或无权限
+func isAuthStatus(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
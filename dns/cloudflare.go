@@ -0,0 +1,273 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/sirupsen/logrus"
+
+	"ddns-ipv6/config"
+)
+
+const cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// Cloudflare 允许的 TTL 范围，ttl 为 1 表示自动
+const (
+	cloudflareMinTTL = 60
+	cloudflareMaxTTL = 86400
+)
+
+// CloudflareProvider Cloudflare DNS
+type CloudflareProvider struct {
+	apiToken string
+	zoneID   string
+	client   *http.Client
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+func NewCloudflareProvider(cfg config.Cloudflare, client *http.Client) *CloudflareProvider {
+	return &CloudflareProvider{
+		apiToken: cfg.APIToken,
+		zoneID:   cfg.ZoneID,
+		client:   client,
+	}
+}
+
+// UpdateRecord 更新域名解析记录，记录不存在且开启 CreateIfMissing 时创建
+func (p *CloudflareProvider) UpdateRecord(ctx context.Context, record Record) error {
+	name := record.Name()
+
+	// 查询记录ID
+	records, err := p.list(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]any{"content": record.Value}
+	if record.TTL == 1 {
+		body["ttl"] = 1
+	} else if ttl := clampTTL("cloudflare", record.TTL, cloudflareMinTTL, cloudflareMaxTTL); ttl > 0 {
+		body["ttl"] = ttl
+	}
+
+	if len(records) == 0 && !record.CreateIfMissing {
+		return recordNotFound(record)
+	}
+	if record.Values != nil {
+		return p.updateSet(ctx, record, records, body)
+	}
+
+	if len(records) == 0 {
+		body["type"] = record.Type
+		body["name"] = name
+		if _, ok := body["ttl"]; !ok {
+			body["ttl"] = 1 // 自动
+		}
+		if err := p.do(ctx, http.MethodPost, "/zones/"+p.zoneID+"/dns_records", body, nil); err != nil {
+			return err
+		}
+		logBootstrapped(record)
+		return nil
+	}
+
+	// 更新记录
+	return p.do(ctx, http.MethodPatch, "/zones/"+p.zoneID+"/dns_records/"+records[0].ID, body, nil)
+}
+
+// CheckRecord 查询记录是否存在
+func (p *CloudflareProvider) CheckRecord(ctx context.Context, record Record) (bool, error) {
+	records, err := p.list(ctx, record)
+	return len(records) > 0, err
+}
+
+// DeleteRecord 删除该名称下的全部记录，记录的当前值与 record 的全部值不一致时不删除
+func (p *CloudflareProvider) DeleteRecord(ctx context.Context, record Record) error {
+	records, err := p.list(ctx, record)
+	if err != nil || len(records) == 0 {
+		return err
+	}
+	var published []string
+	for _, r := range records {
+		published = append(published, r.Content)
+	}
+	if !sameValues(record, published) {
+		return recordChanged(record, published)
+	}
+	for _, r := range records {
+		if err := p.do(ctx, http.MethodDelete, "/zones/"+p.zoneID+"/dns_records/"+r.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdatePTR 在账号中包含该地址的最具体的反向区域内创建或更新 PTR 记录，
+// API 令牌需要该区域的 Zone:Read 与 DNS:Edit 权限
+func (p *CloudflareProvider) UpdatePTR(ctx context.Context, ip, hostname string) error {
+	name, err := reverseName(ip)
+	if err != nil {
+		return backoff.Permanent(err)
+	}
+
+	query := url.Values{}
+	query.Set("name", "ends_with:"+reverseSuffix(name))
+	query.Set("per_page", "50")
+	var zones []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := p.do(ctx, http.MethodGet, "/zones?"+query.Encode(), nil, &zones); err != nil {
+		return err
+	}
+	names := make([]string, len(zones))
+	for i, z := range zones {
+		names[i] = z.Name
+	}
+	zone, subDomain, err := reverseZone(ip, name, names)
+	if err != nil {
+		return err
+	}
+
+	reverse := &CloudflareProvider{apiToken: p.apiToken, client: p.client}
+	for _, z := range zones {
+		if strings.EqualFold(strings.TrimSuffix(z.Name, "."), zone) {
+			reverse.zoneID = z.ID
+		}
+	}
+	return reverse.UpdateRecord(ctx, Record{SubDomain: subDomain, Domain: zone, Type: "PTR", Value: hostname, CreateIfMissing: true})
+}
+
+// list 查询与记录名称、类型一致的全部记录
+func (p *CloudflareProvider) list(ctx context.Context, record Record) ([]cloudflareRecord, error) {
+	query := url.Values{}
+	query.Set("type", record.Type)
+	query.Set("name", record.Name())
+
+	var records []cloudflareRecord
+	if err := p.do(ctx, http.MethodGet, "/zones/"+p.zoneID+"/dns_records?"+query.Encode(), nil, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// updateSet 使该名称下恰好有 record.Values 中的记录：已有的值保留，
+// 多余的记录改为缺少的值，仍然缺少的新建，剩余的删除
+func (p *CloudflareProvider) updateSet(ctx context.Context, record Record, existing []cloudflareRecord, body map[string]any) error {
+	wanted := make(map[string]bool, len(record.Values))
+	for _, value := range record.Values {
+		wanted[value] = true
+	}
+	var spare []cloudflareRecord
+	for _, r := range existing {
+		if wanted[r.Content] {
+			delete(wanted, r.Content)
+		} else {
+			spare = append(spare, r)
+		}
+	}
+
+	for _, value := range record.Values {
+		if !wanted[value] {
+			continue
+		}
+		item := map[string]any{"content": value}
+		for key, v := range body {
+			if key != "content" {
+				item[key] = v
+			}
+		}
+		if len(spare) > 0 {
+			if err := p.do(ctx, http.MethodPatch, "/zones/"+p.zoneID+"/dns_records/"+spare[0].ID, item, nil); err != nil {
+				return err
+			}
+			spare = spare[1:]
+			continue
+		}
+		item["type"] = record.Type
+		item["name"] = record.Name()
+		if _, ok := item["ttl"]; !ok {
+			item["ttl"] = 1 // 自动
+		}
+		if err := p.do(ctx, http.MethodPost, "/zones/"+p.zoneID+"/dns_records", item, nil); err != nil {
+			return err
+		}
+		logrus.Printf("Added %s record %s with %s", record.Type, record.Name(), value)
+	}
+
+	for _, r := range spare {
+		if err := p.do(ctx, http.MethodDelete, "/zones/"+p.zoneID+"/dns_records/"+r.ID, nil, nil); err != nil {
+			return err
+		}
+		logrus.Printf("Removed %s record %s with %s", record.Type, record.Name(), r.Content)
+	}
+	return nil
+}
+
+// do 调用 Cloudflare API 并解析 result 字段
+func (p *CloudflareProvider) do(ctx context.Context, method, path string, reqBody, result any) error {
+	var body bytes.Buffer
+	if reqBody != nil {
+		if err := json.NewEncoder(&body).Encode(reqBody); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, cloudflareAPI+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err := fmt.Errorf("cloudflare: request failed with status %d", resp.StatusCode)
+		return &RateLimitError{Err: err, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+
+	var cfResp cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cfResp); err != nil {
+		return fmt.Errorf("cloudflare: decode response (status %d): %v", resp.StatusCode, err)
+	}
+	if !cfResp.Success {
+		err := fmt.Errorf("cloudflare: request failed with status %d", resp.StatusCode)
+		if len(cfResp.Errors) > 0 {
+			err = fmt.Errorf("cloudflare: %s (code %d)", cfResp.Errors[0].Message, cfResp.Errors[0].Code)
+		}
+		if isAuthStatus(resp.StatusCode) {
+			return authError(err)
+		}
+		return err
+	}
+
+	if result != nil {
+		return json.Unmarshal(cfResp.Result, result)
+	}
+	return nil
+}
@@ -1,85 +1,358 @@
 package dns
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
 
 	"github.com/cenkalti/backoff/v4"
-	"github.com/tencentcloud/tencentcloud-sdk-go-intl-en/tencentcloud/common"
-	dnspod "github.com/tencentcloud/tencentcloud-sdk-go-intl-en/tencentcloud/dnspod/v20210323"
+	"github.com/sirupsen/logrus"
 )
 
+// DNSCache 按记录类型(A/AAAA)分别缓存最近一次更新的地址
 type DNSCache struct {
-	CurrentIP  string
-	LastUpdate time.Time
+	entries map[string]CacheEntry
+	// path 缓存持久化文件，为空时仅保存在内存中
+	path string
 	sync.RWMutex
 }
 
-func NewDNSCache() *DNSCache {
-	return &DNSCache{}
+type CacheEntry struct {
+	IP         string    `json:"ip"`
+	LastUpdate time.Time `json:"lastUpdate"`
+	// RecordIDs 服务商侧的记录ID，按完整域名索引，避免每次更新前都查询记录
+	RecordIDs map[string]string `json:"recordIds,omitempty"`
+	// Provider/Records 地址已发布到的服务商与完整域名，均为空表示未知(旧版本缓存或由解析结果设置)
+	Provider string   `json:"provider,omitempty"`
+	Records  []string `json:"records,omitempty"`
 }
 
-func (c *DNSCache) UpdateIP(ip string) {
+// NewDNSCache 创建缓存，path 非空时从该文件加载，文件不存在或损坏时视为空缓存
+func NewDNSCache(path string) *DNSCache {
+	c := &DNSCache{entries: make(map[string]CacheEntry), path: path}
+	if path == "" {
+		return c
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Warnf("Failed to read cache file %s, starting with empty cache: %v", path, err)
+		}
+		return c
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		logrus.Warnf("Cache file %s is corrupt, starting with empty cache: %v", path, err)
+		c.entries = make(map[string]CacheEntry)
+	}
+	return c
+}
+
+// UpdateIP 记录地址已通过 provider 发布到 records，只应在所有记录都确认更新成功后调用，
+// 否则下一轮会误认为地址未变化而跳过仍需的更新
+func (c *DNSCache) UpdateIP(recordType, ip, provider string, records []string) {
 	c.Lock()
 	defer c.Unlock()
-	c.CurrentIP = ip
-	c.LastUpdate = time.Now()
+	entry := c.entries[recordType]
+	entry.IP = ip
+	entry.LastUpdate = time.Now()
+	entry.Provider = provider
+	entry.Records = slices.Clone(records)
+	c.entries[recordType] = entry
+	c.persist()
 }
 
-func (c *DNSCache) GetIP() (string, time.Time) {
+// RecordID 返回缓存的记录ID，未缓存时返回空字符串
+func (c *DNSCache) RecordID(recordType, name string) string {
 	c.RLock()
 	defer c.RUnlock()
-	return c.CurrentIP, c.LastUpdate
+	return c.entries[recordType].RecordIDs[name]
 }
 
-// UpdateDNSRecord 更新域名解析记录
-func UpdateDNSRecord(client *dnspod.Client, config config.Config, ipv6 string) error {
-	// 获取记录列表以找到需要更新的记录ID
-	listRequest := dnspod.NewDescribeRecordListRequest()
-	listRequest.Domain = common.StringPtr(config.Domain.Domain)
-	listRequest.Subdomain = common.StringPtr(config.Domain.SubDomain)
+// SetRecordID 缓存记录ID，id 为空时删除缓存
+func (c *DNSCache) SetRecordID(recordType, name, id string) {
+	c.Lock()
+	defer c.Unlock()
+	entry := c.entries[recordType]
+	if entry.RecordIDs[name] == id {
+		return
+	}
+	if id == "" {
+		delete(entry.RecordIDs, name)
+	} else {
+		if entry.RecordIDs == nil {
+			entry.RecordIDs = make(map[string]string)
+		}
+		entry.RecordIDs[name] = id
+	}
+	c.entries[recordType] = entry
+	c.persist()
+}
 
-	listResponse, err := client.DescribeRecordList(listRequest)
+// persist 写入缓存文件，失败时只记录日志
+func (c *DNSCache) persist() {
+	if err := c.save(); err != nil {
+		logrus.Warnf("Failed to persist cache to %s: %v", c.path, err)
+	}
+}
+
+// Flush 将缓存写入持久化文件
+func (c *DNSCache) Flush() error {
+	c.RLock()
+	defer c.RUnlock()
+	return c.save()
+}
+
+// save 将缓存写入文件，先写临时文件再重命名以免中途退出导致文件损坏
+func (c *DNSCache) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
 	if err != nil {
 		return err
 	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// SeedIP 设置缓存的地址而不修改最后更新时间，ip 为空时清除缓存的地址使下次检测必定更新
+func (c *DNSCache) SeedIP(recordType, ip string) {
+	c.Lock()
+	defer c.Unlock()
+	entry := c.entries[recordType]
+	if entry.IP == ip {
+		return
+	}
+	entry.IP = ip
+	entry.Provider = ""
+	entry.Records = nil
+	c.entries[recordType] = entry
+	c.persist()
+}
 
-	var recordID *uint64
-	for _, record := range listResponse.Response.RecordList {
-		if *record.Type == "AAAA" && *record.Name == config.Domain.SubDomain {
-			recordID = record.RecordId
-			break
+// Unpublished 返回 records 中缓存的地址尚未通过 provider 发布的记录，服务商变化时返回全部记录；
+// 不知道发布到了哪些记录时视为全部已发布
+func (c *DNSCache) Unpublished(recordType, provider string, records []string) []string {
+	c.RLock()
+	defer c.RUnlock()
+	entry := c.entries[recordType]
+	if entry.Provider == "" && entry.Records == nil {
+		return nil
+	}
+	if entry.Provider != provider {
+		return records
+	}
+	var missing []string
+	for _, record := range records {
+		if !slices.Contains(entry.Records, record) {
+			missing = append(missing, record)
 		}
 	}
+	return missing
+}
 
-	if recordID == nil {
-		return fmt.Errorf("no matching AAAA record found for subdomain %s", config.Domain.SubDomain)
+func (c *DNSCache) GetIP(recordType string) (string, time.Time) {
+	c.RLock()
+	defer c.RUnlock()
+	entry := c.entries[recordType]
+	return entry.IP, entry.LastUpdate
+}
+
+// UpdateDNSRecordWithRetry 添加重试机制的更新函数，按服务商的重试配置进行带随机抖动的指数退避，ctx 取消时停止重试
+func UpdateDNSRecordWithRetry(ctx context.Context, provider Provider, config config.Config, subDomain, recordType, ip string) error {
+	record := newRecord(config, subDomain, recordType, ip)
+	if config.DryRun {
+		logDryRun(ctx, record)
+		return nil
 	}
 
-	// 更新记录
-	modifyRequest := dnspod.NewModifyRecordRequest()
-	modifyRequest.Domain = common.StringPtr(config.Domain.Domain)
-	modifyRequest.RecordId = recordID
-	modifyRequest.SubDomain = common.StringPtr(config.Domain.SubDomain)
-	modifyRequest.RecordType = common.StringPtr("AAAA")
-	modifyRequest.RecordLine = common.StringPtr("默认")
-	modifyRequest.Value = common.StringPtr(ipv6)
+	attempts := 0
+	var lastErr error
+	operation := func() error {
+		attempts++
+		lastErr = provider.UpdateRecord(ctx, record)
+		return lastErr
+	}
 
-	_, err = client.ModifyRecord(modifyRequest)
-	return err
+	if err := backoff.Retry(operation, retryPolicy(ctx, config.ProviderRetry(), &lastErr)); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil && lastErr == nil {
+			lastErr = ctxErr
+		}
+		return fmt.Errorf("giving up after %d attempts: %w", attempts, lastErr)
+	}
+	return nil
 }
 
-// UpdateDNSRecordWithRetry 添加重试机制的更新函数
-func UpdateDNSRecordWithRetry(client *dnspod.Client, config config.Config, ipv6 string) error {
+// UpdateDNSRecordsWithRetry 将多个子域名的记录更新为 ip，返回与 subDomains 一一对应的错误。
+// 服务商支持批量更新时合并为一次请求，重试时只重新提交失败的记录；否则按 domain.updateConcurrency
+// 同时调用 UpdateDNSRecordWithRetry，单条记录失败不影响其余记录。
+// 凭证被拒绝时尚未开始的记录不再尝试，直接返回同一错误。
+func UpdateDNSRecordsWithRetry(ctx context.Context, provider Provider, config config.Config, subDomains []string, recordType, ip string) []error {
+	errs := make([]error, len(subDomains))
+	batch, ok := provider.(BatchProvider)
+	if !ok || len(subDomains) < 2 || config.DryRun {
+		updateConcurrently(ctx, provider, config, subDomains, recordType, ip, errs)
+		return errs
+	}
+
+	pending := make([]int, len(subDomains))
+	for i := range subDomains {
+		pending[i] = i
+	}
+	attempts := 0
+	var lastErr error
 	operation := func() error {
-		return UpdateDNSRecord(client, config, ipv6)
+		attempts++
+		records := make([]Record, len(pending))
+		for j, i := range pending {
+			records[j] = newRecord(config, subDomains[i], recordType, ip)
+		}
+
+		var failed []int
+		lastErr = nil
+		for j, err := range batch.UpdateRecords(ctx, records) {
+			errs[pending[j]] = err
+			if err != nil {
+				failed = append(failed, pending[j])
+				// 任一记录凭证被拒绝时停止重试，被限流时整批按限流等待
+				if lastErr == nil || IsAuthError(err) || (IsRateLimited(err) && !IsAuthError(lastErr)) {
+					lastErr = err
+				}
+			}
+		}
+		pending = failed
+		if len(failed) > 0 {
+			return fmt.Errorf("%d records failed: %w", len(failed), lastErr)
+		}
+		return nil
+	}
+
+	if err := backoff.Retry(operation, retryPolicy(ctx, config.ProviderRetry(), &lastErr)); err != nil {
+		for _, i := range pending {
+			if errs[i] == nil {
+				errs[i] = ctx.Err()
+			}
+			errs[i] = fmt.Errorf("giving up after %d attempts: %w", attempts, errs[i])
+		}
+	}
+	return errs
+}
+
+// updateConcurrently 以最多 domain.updateConcurrency 个 worker 逐条更新记录，结果按下标写入 errs
+func updateConcurrently(ctx context.Context, provider Provider, config config.Config, subDomains []string, recordType, ip string, errs []error) {
+	workers := min(max(config.Domain.UpdateConcurrency, 1), len(subDomains))
+	indexes := make(chan int)
+	var mu sync.Mutex
+	var authErr error
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				mu.Lock()
+				skip := authErr
+				mu.Unlock()
+				if skip != nil {
+					errs[i] = fmt.Errorf("skipped: %w", skip)
+					continue
+				}
+				errs[i] = UpdateDNSRecordWithRetry(ctx, provider, config, subDomains[i], recordType, ip)
+				if IsAuthError(errs[i]) {
+					mu.Lock()
+					if authErr == nil {
+						authErr = errs[i]
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range subDomains {
+		indexes <- i
 	}
+	close(indexes)
+	wg.Wait()
+}
+
+// newRecord 按域名配置创建子域名的记录，空子域名按主域名 "@" 处理。
+// 开启 network.allAddresses 时 ip 为逗号分隔的地址集合，记录的 Values 为其中的全部地址
+func newRecord(cfg config.Config, subDomain, recordType, ip string) Record {
+	if subDomain == "" {
+		subDomain = config.Apex
+	}
+	var values []string
+	if recordType == "AAAA" && cfg.Network.AllAddresses {
+		values = strings.Split(ip, ",")
+		ip = values[0]
+	}
+	return Record{
+		Values:          values,
+		SubDomain:       subDomain,
+		Domain:          cfg.Domain.Domain,
+		Type:            recordType,
+		Value:           ip,
+		TTL:             cfg.Domain.TTL,
+		CreateIfMissing: cfg.Domain.CreateIfMissing,
+	}
+}
 
+// retryPolicy 按配置创建带随机抖动的指数退避策略，ctx 取消时停止重试。
+// lastErr 指向最近一次尝试的错误，被服务商限流时改用更长的等待时间。
+func retryPolicy(ctx context.Context, retry config.Retry, lastErr *error) backoff.BackOff {
 	backoffConfig := backoff.NewExponentialBackOff()
-	backoffConfig.MaxElapsedTime = 5 * time.Minute
+	backoffConfig.InitialInterval = time.Duration(retry.BaseDelay * float64(time.Second))
+	backoffConfig.MaxInterval = time.Duration(retry.MaxDelay * float64(time.Second))
+	backoffConfig.RandomizationFactor = retry.Jitter
+	backoffConfig.MaxElapsedTime = 0
+
+	maxRetries := uint64(0)
+	if retry.MaxAttempts > 1 {
+		maxRetries = uint64(retry.MaxAttempts - 1)
+	}
+	rateLimited := &rateLimitBackOff{BackOff: backoffConfig, lastErr: lastErr}
+	return backoff.WithContext(backoff.WithMaxRetries(rateLimited, maxRetries), ctx)
+}
+
+// logDryRun 记录演练模式下将要执行的变更，当前记录值通过 DNS 解析获得
+func logDryRun(ctx context.Context, record Record) {
+	name := record.Name()
+	network := "ip6"
+	if record.Type == "A" {
+		network = "ip4"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	current := "unknown"
+	if addrs, err := net.DefaultResolver.LookupIP(ctx, network, name); err == nil && len(addrs) > 0 {
+		values := make([]string, len(addrs))
+		for i, addr := range addrs {
+			values[i] = addr.String()
+		}
+		current = strings.Join(values, ",")
+	}
 
-	return backoff.Retry(operation, backoffConfig)
+	httpclient.Logger(ctx).WithFields(logrus.Fields{
+		"record": name,
+		"type":   record.Type,
+		"from":   current,
+		"to":     strings.Join(record.AllValues(), ","),
+		"ttl":    record.TTL,
+	}).Printf("[dry-run] Would update %s record %s: %s -> %s", record.Type, name, current, strings.Join(record.AllValues(), ","))
 }
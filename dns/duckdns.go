@@ -0,0 +1,65 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ddns-ipv6/config"
+)
+
+const duckDNSAPI = "https://www.duckdns.org/update"
+
+// DuckDNSProvider DuckDNS 免费动态域名，接口本身即为更新或创建，无需查询记录
+type DuckDNSProvider struct {
+	token     string
+	subDomain string
+	client    *http.Client
+}
+
+func NewDuckDNSProvider(cfg config.DuckDNS, client *http.Client) *DuckDNSProvider {
+	return &DuckDNSProvider{
+		token:     cfg.Token,
+		subDomain: cfg.SubDomain,
+		client:    client,
+	}
+}
+
+// UpdateRecord 更新域名解析记录，未配置 duckdns.subDomain 时使用记录的子域名；DuckDNS 子域名需预先注册，CreateIfMissing 不起作用
+func (p *DuckDNSProvider) UpdateRecord(ctx context.Context, record Record) error {
+	name := p.subDomain
+	if name == "" {
+		name = record.SubDomain
+	}
+
+	query := url.Values{}
+	query.Set("domains", name)
+	query.Set("token", p.token)
+	if record.Type == "A" {
+		query.Set("ip", record.Value)
+	} else {
+		query.Set("ipv6", record.Value)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, duckDNSAPI+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return err
+	}
+	if result := strings.TrimSpace(string(body)); result != "OK" {
+		return fmt.Errorf("duckdns: update %s failed (status %d): %s", name, resp.StatusCode, result)
+	}
+	return nil
+}
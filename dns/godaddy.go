@@ -0,0 +1,182 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"ddns-ipv6/config"
+)
+
+const (
+	godaddyAPI    = "https://api.godaddy.com"
+	godaddyOTEAPI = "https://api.ote-godaddy.com"
+)
+
+// GoDaddy 允许的 TTL 范围
+const (
+	godaddyMinTTL = 600
+	godaddyMaxTTL = 604800
+)
+
+// GoDaddyProvider GoDaddy DNS
+type GoDaddyProvider struct {
+	endpoint string
+	apiKey   string
+	secret   string
+	client   *http.Client
+}
+
+func NewGoDaddyProvider(cfg config.GoDaddy, client *http.Client) *GoDaddyProvider {
+	endpoint := godaddyAPI
+	if cfg.UseOTE {
+		endpoint = godaddyOTEAPI
+	}
+	return &GoDaddyProvider{
+		endpoint: endpoint,
+		apiKey:   cfg.APIKey,
+		secret:   cfg.APISecret,
+		client:   client,
+	}
+}
+
+// UpdateRecord 替换子域名下该类型的全部记录，记录不存在且开启 CreateIfMissing 时创建
+func (p *GoDaddyProvider) UpdateRecord(ctx context.Context, record Record) error {
+	exists, err := p.exists(ctx, record)
+	if err != nil {
+		return err
+	}
+	if !exists && !record.CreateIfMissing {
+		return recordNotFound(record)
+	}
+
+	ttl := clampTTL("godaddy", record.TTL, godaddyMinTTL, godaddyMaxTTL)
+	var items []map[string]any
+	for _, value := range record.AllValues() {
+		item := map[string]any{"data": value}
+		if ttl > 0 {
+			item["ttl"] = ttl
+		}
+		items = append(items, item)
+	}
+	body, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.do(ctx, http.MethodPut, record, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if !exists {
+			logBootstrapped(record)
+		}
+		return nil
+	}
+	return p.responseError(resp, record)
+}
+
+// CheckRecord 查询记录是否存在
+func (p *GoDaddyProvider) CheckRecord(ctx context.Context, record Record) (bool, error) {
+	return p.exists(ctx, record)
+}
+
+// DeleteRecord 删除子域名下该类型的全部记录，记录的当前值与 record 的全部值不一致时不删除
+func (p *GoDaddyProvider) DeleteRecord(ctx context.Context, record Record) error {
+	published, err := p.values(ctx, record)
+	if err != nil || len(published) == 0 {
+		return err
+	}
+	if !sameValues(record, published) {
+		return recordChanged(record, published)
+	}
+
+	resp, err := p.do(ctx, http.MethodDelete, record, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if (resp.StatusCode < 200 || resp.StatusCode >= 300) && resp.StatusCode != http.StatusNotFound {
+		return p.responseError(resp, record)
+	}
+	return nil
+}
+
+// exists 查询子域名下是否已有该类型的记录
+func (p *GoDaddyProvider) exists(ctx context.Context, record Record) (bool, error) {
+	values, err := p.values(ctx, record)
+	return len(values) > 0, err
+}
+
+// values 返回子域名下该类型的全部记录值
+func (p *GoDaddyProvider) values(ctx context.Context, record Record) ([]string, error) {
+	resp, err := p.do(ctx, http.MethodGet, record, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, p.responseError(resp, record)
+	}
+	var records []struct {
+		Data string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, fmt.Errorf("godaddy: decode records of %s: %v", record.Name(), err)
+	}
+	var values []string
+	for _, r := range records {
+		values = append(values, r.Data)
+	}
+	return values, nil
+}
+
+// do 向记录对应的接口路径发送请求
+func (p *GoDaddyProvider) do(ctx context.Context, method string, record Record, body io.Reader) (*http.Response, error) {
+	path := fmt.Sprintf("/v1/domains/%s/records/%s/%s",
+		url.PathEscape(record.Domain), url.PathEscape(record.Type), url.PathEscape(record.SubDomain))
+	req, err := http.NewRequestWithContext(ctx, method, p.endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "sso-key "+p.apiKey+":"+p.secret)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return p.client.Do(req)
+}
+
+// responseError 将非 2xx 响应转换为错误
+func (p *GoDaddyProvider) responseError(resp *http.Response, record Record) error {
+	var result struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(respBody, &result) != nil || result.Message == "" {
+		result.Message = string(bytes.TrimSpace(respBody))
+	}
+	err := fmt.Errorf("godaddy: update %s failed with status %d: %s (%s)", record.Name(), resp.StatusCode, result.Message, result.Code)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &RateLimitError{Err: err, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	if isAuthStatus(resp.StatusCode) {
+		return authError(err)
+	}
+	// 除限流外的 4xx 为请求或凭证问题，重试没有意义
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return backoff.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,28 @@
+package dns
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"ddns-ipv6/httpclient"
+)
+
+// NewHTTPClient 创建调用服务商接口的 HTTP 客户端，resolver 非空时通过该 DNS 服务器解析接口域名，
+// 使接口调用不依赖可能不可用的系统解析器；timeout 为单个请求的总超时。
+// 请求统一带上 User-Agent 与 ctx 中的请求ID(见 httpclient.Wrap)。
+func NewHTTPClient(resolver string, timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Resolver:  newResolver(resolver),
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: httpclient.Wrap(transport), Timeout: timeout}
+}
+
+// opaqueTransport 隐藏底层的 *http.Transport，避免 SDK 按自己的设置改写拨号方式
+type opaqueTransport struct {
+	http.RoundTripper
+}
@@ -0,0 +1,299 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"ddns-ipv6/config"
+)
+
+// 华为云 DNS 允许的 TTL 范围
+const (
+	huaweiMinTTL = 1
+	huaweiMaxTTL = 2147483647
+)
+
+// huaweiSignAlgorithm 华为云 API 网关的 AK/SK 签名算法
+const huaweiSignAlgorithm = "SDK-HMAC-SHA256"
+
+// HuaweiProvider 华为云 DNS，通过 v2 接口按名称与类型查找记录集并更新
+type HuaweiProvider struct {
+	endpoint  string
+	host      string
+	accessKey string
+	secretKey string
+	zoneID    string
+	client    *http.Client
+}
+
+func NewHuaweiProvider(cfg config.Huawei, client *http.Client) *HuaweiProvider {
+	host := fmt.Sprintf("dns.%s.myhuaweicloud.com", cfg.Region)
+	return &HuaweiProvider{
+		endpoint:  "https://" + host,
+		host:      host,
+		accessKey: cfg.AccessKeyId,
+		secretKey: cfg.SecretAccessKey,
+		zoneID:    cfg.ZoneID,
+		client:    client,
+	}
+}
+
+// huaweiRecordSet 记录集，名称为以点结尾的完整域名
+type huaweiRecordSet struct {
+	ID      string   `json:"id,omitempty"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl,omitempty"`
+	Records []string `json:"records"`
+}
+
+// UpdateRecord 将记录集的值替换为记录的全部值，记录集不存在且开启 CreateIfMissing 时创建
+func (p *HuaweiProvider) UpdateRecord(ctx context.Context, record Record) error {
+	existing, err := p.find(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	recordSet := huaweiRecordSet{
+		Name:    record.Name() + ".",
+		Type:    record.Type,
+		TTL:     clampTTL("huawei", record.TTL, huaweiMinTTL, huaweiMaxTTL),
+		Records: record.AllValues(),
+	}
+	path := fmt.Sprintf("/v2/zones/%s/recordsets", url.PathEscape(p.zoneID))
+	method := http.MethodPost
+	if existing != nil {
+		path += "/" + url.PathEscape(existing.ID)
+		method = http.MethodPut
+	} else if !record.CreateIfMissing {
+		return recordNotFound(record)
+	}
+
+	body, err := json.Marshal(recordSet)
+	if err != nil {
+		return err
+	}
+	action := "update"
+	if existing == nil {
+		action = "create"
+	}
+	if err := p.do(ctx, method, path, nil, body, nil); err != nil {
+		return fmt.Errorf("huawei: %s %s: %w", action, record.Name(), err)
+	}
+	if existing == nil {
+		logBootstrapped(record)
+	}
+	return nil
+}
+
+// CheckRecord 查询记录集是否存在
+func (p *HuaweiProvider) CheckRecord(ctx context.Context, record Record) (bool, error) {
+	existing, err := p.find(ctx, record)
+	return existing != nil, err
+}
+
+// DeleteRecord 删除记录集，记录集的当前值与 record 的全部值不一致时不删除
+func (p *HuaweiProvider) DeleteRecord(ctx context.Context, record Record) error {
+	existing, err := p.find(ctx, record)
+	if err != nil || existing == nil {
+		return err
+	}
+	if !sameValues(record, existing.Records) {
+		return recordChanged(record, existing.Records)
+	}
+	path := fmt.Sprintf("/v2/zones/%s/recordsets/%s", url.PathEscape(p.zoneID), url.PathEscape(existing.ID))
+	if err := p.do(ctx, http.MethodDelete, path, nil, nil, nil); err != nil {
+		return fmt.Errorf("huawei: delete %s: %w", record.Name(), err)
+	}
+	return nil
+}
+
+// find 查询与记录名称、类型完全一致的记录集，不存在时返回 nil
+func (p *HuaweiProvider) find(ctx context.Context, record Record) (*huaweiRecordSet, error) {
+	name := record.Name() + "."
+	query := url.Values{
+		"name":        {name},
+		"type":        {record.Type},
+		"search_mode": {"equal"},
+	}
+	var result struct {
+		RecordSets []huaweiRecordSet `json:"recordsets"`
+	}
+	path := fmt.Sprintf("/v2/zones/%s/recordsets", url.PathEscape(p.zoneID))
+	if err := p.do(ctx, http.MethodGet, path, query, nil, &result); err != nil {
+		return nil, fmt.Errorf("huawei: query %s: %w", record.Name(), err)
+	}
+	for _, recordSet := range result.RecordSets {
+		if strings.EqualFold(recordSet.Name, name) && recordSet.Type == record.Type {
+			return &recordSet, nil
+		}
+	}
+	return nil, nil
+}
+
+// do 发送签名后的请求，2xx 时将响应解码到 out(可为 nil)，否则返回描述性错误
+func (p *HuaweiProvider) do(ctx context.Context, method, path string, query url.Values, body []byte, out any) error {
+	target := p.endpoint + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	p.sign(req, body, time.Now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return huaweiError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %v", err)
+	}
+	return nil
+}
+
+// huaweiError 将错误响应转换为错误，DNS 服务与 API 网关的错误体字段名不同
+func huaweiError(resp *http.Response) error {
+	var result struct {
+		Code         string `json:"code"`
+		Message      string `json:"message"`
+		ErrorCode    string `json:"error_code"`
+		ErrorMessage string `json:"error_msg"`
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(respBody, &result) == nil {
+		if result.Code == "" {
+			result.Code = result.ErrorCode
+		}
+		if result.Message == "" {
+			result.Message = result.ErrorMessage
+		}
+	}
+	if result.Message == "" {
+		result.Message = string(bytes.TrimSpace(respBody))
+	}
+	err := fmt.Errorf("status %d: %s (%s)", resp.StatusCode, result.Message, result.Code)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &RateLimitError{Err: err, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	if isAuthStatus(resp.StatusCode) {
+		return authError(err)
+	}
+	// 除限流外的 4xx 为请求参数问题，重试没有意义
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return backoff.Permanent(err)
+	}
+	return err
+}
+
+// sign 按华为云 API 网关的 SDK-HMAC-SHA256 算法为请求添加签名
+func (p *HuaweiProvider) sign(req *http.Request, body []byte, now time.Time) {
+	date := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Sdk-Date", date)
+	req.Host = p.host
+
+	headers := map[string]string{"host": p.host, "x-sdk-date": date}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		huaweiCanonicalURI(req.URL.Path),
+		huaweiCanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := huaweiSignAlgorithm + "\n" + date + "\n" + hex.EncodeToString(requestHash[:])
+	mac := hmac.New(sha256.New, []byte(p.secretKey))
+	mac.Write([]byte(stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Access=%s, SignedHeaders=%s, Signature=%s",
+		huaweiSignAlgorithm, p.accessKey, signedHeaders, hex.EncodeToString(mac.Sum(nil))))
+}
+
+// huaweiCanonicalURI 逐段转义路径，签名要求以 / 结尾
+func huaweiCanonicalURI(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = huaweiEscape(segment)
+	}
+	uri := strings.Join(segments, "/")
+	if !strings.HasSuffix(uri, "/") {
+		uri += "/"
+	}
+	return uri
+}
+
+// huaweiCanonicalQuery 按参数名排序后拼接查询参数
+func huaweiCanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, huaweiEscape(key)+"="+huaweiEscape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// huaweiEscape 除字母、数字与 -_.~ 外全部按 %XX 转义
+func huaweiEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package dns
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ddns-ipv6/config"
+)
+
+const namecheapAPI = "https://dynamicdns.park-your-domain.com/update"
+
+// NamecheapProvider Namecheap 动态域名，使用域名的 Dynamic DNS 密码，接口本身即为更新
+type NamecheapProvider struct {
+	password string
+	client   *http.Client
+}
+
+type namecheapResponse struct {
+	ErrCount int `xml:"ErrCount"`
+	Errors   struct {
+		Items []struct {
+			Text string `xml:",chardata"`
+		} `xml:",any"`
+	} `xml:"errors"`
+}
+
+func NewNamecheapProvider(cfg config.Namecheap, client *http.Client) *NamecheapProvider {
+	return &NamecheapProvider{
+		password: cfg.Password,
+		client:   client,
+	}
+}
+
+// UpdateRecord 更新域名解析记录，接口不支持设置 TTL；主机记录需在控制台预先添加，CreateIfMissing 不起作用
+func (p *NamecheapProvider) UpdateRecord(ctx context.Context, record Record) error {
+	query := url.Values{}
+	query.Set("host", record.SubDomain)
+	query.Set("domain", record.Domain)
+	query.Set("password", p.password)
+	query.Set("ip", record.Value)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, namecheapAPI+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result namecheapResponse
+	decoder := xml.NewDecoder(resp.Body)
+	// 响应声明为 utf-16，实际内容为 UTF-8，按原样读取
+	decoder.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	if err := decoder.Decode(&result); err != nil {
+		return fmt.Errorf("namecheap: decode response (status %d): %v", resp.StatusCode, err)
+	}
+	if result.ErrCount > 0 {
+		var messages []string
+		for _, item := range result.Errors.Items {
+			messages = append(messages, strings.TrimSpace(item.Text))
+		}
+		err := fmt.Errorf("namecheap: update %s failed: %s", record.Name(), strings.Join(messages, "; "))
+		// 密码错误时返回 Passwords do not match
+		if strings.Contains(strings.ToLower(err.Error()), "password") {
+			return authError(err)
+		}
+		return err
+	}
+	return nil
+}
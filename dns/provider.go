@@ -0,0 +1,187 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/sirupsen/logrus"
+
+	"ddns-ipv6/config"
+)
+
+// Record 需要更新的一条解析记录
+type Record struct {
+	// SubDomain 子域名，主域名本身为 "@"，各服务商按接口要求转换
+	SubDomain string
+	Domain    string
+	// Type 记录类型: A 或 AAAA
+	Type  string
+	Value string
+	// Values 非空时为记录集的全部值(network.allAddresses)，服务商应使该名称下恰好有这些记录，Value 为其中第一个
+	Values []string
+	// TTL 记录缓存时间(秒)，为 0 时使用服务商默认值
+	TTL int
+	// CreateIfMissing 记录不存在时以 Value 与 TTL 创建，否则返回 ErrRecordNotFound
+	CreateIfMissing bool
+}
+
+// ErrRecordNotFound 记录不存在且未开启 CreateIfMissing
+var ErrRecordNotFound = errors.New("record not found")
+
+// recordNotFound 返回记录不存在的错误，重试无法解决，不再重试
+func recordNotFound(record Record) error {
+	return backoff.Permanent(fmt.Errorf("%w: %s record %s does not exist, enable domain.createIfMissing to create it",
+		ErrRecordNotFound, record.Type, record.Name()))
+}
+
+// logBootstrapped 记录新创建的记录
+func logBootstrapped(record Record) {
+	logrus.Printf("Bootstrapped missing %s record %s with %s", record.Type, record.Name(), strings.Join(record.AllValues(), ","))
+}
+
+// AllValues 返回记录的全部值
+func (r Record) AllValues() []string {
+	if len(r.Values) > 0 {
+		return r.Values
+	}
+	return []string{r.Value}
+}
+
+// Name 返回记录的完整域名，主域名记录返回 Domain 本身
+func (r Record) Name() string {
+	if r.IsApex() {
+		return r.Domain
+	}
+	return r.SubDomain + "." + r.Domain
+}
+
+// IsApex 判断是否为主域名本身的记录
+func (r Record) IsApex() bool {
+	return r.SubDomain == "" || r.SubDomain == config.Apex
+}
+
+// Provider DNS 服务商接口
+type Provider interface {
+	// UpdateRecord 将 record 对应的解析记录更新为 record.Value，ctx 取消时应尽快返回
+	UpdateRecord(ctx context.Context, record Record) error
+}
+
+// BatchProvider 支持在一次请求中更新多条记录的服务商
+type BatchProvider interface {
+	Provider
+	// UpdateRecords 批量更新记录，返回与 records 一一对应的错误，nil 表示该记录已更新
+	UpdateRecords(ctx context.Context, records []Record) []error
+}
+
+// Checker 支持只读查询记录的服务商，用于 -check 在不修改记录的情况下验证凭证
+type Checker interface {
+	// CheckRecord 查询 record 对应的记录是否存在，凭证无效时返回 AuthError
+	CheckRecord(ctx context.Context, record Record) (bool, error)
+}
+
+// ErrCheckUnsupported 服务商没有只读查询接口，无法在不更新记录的情况下验证凭证
+var ErrCheckUnsupported = errors.New("provider has no read-only API")
+
+// CheckRecord 通过服务商的只读接口查询 subDomain 下 recordType 类型的记录是否存在，
+// 服务商未实现 Checker 时返回 ErrCheckUnsupported
+func CheckRecord(ctx context.Context, provider Provider, cfg config.Config, subDomain, recordType string) (bool, error) {
+	checker, ok := provider.(Checker)
+	if !ok {
+		return false, ErrCheckUnsupported
+	}
+	return checker.CheckRecord(ctx, newRecord(cfg, subDomain, recordType, ""))
+}
+
+// Deleter 支持删除记录的服务商，用于 removeRecordOnExit
+type Deleter interface {
+	// DeleteRecord 删除 record 对应的记录；记录已不存在时返回 nil，
+	// 记录的当前值与 record 的全部值不一致(已被其他实例接管)时不删除，返回 ErrRecordChanged
+	DeleteRecord(ctx context.Context, record Record) error
+}
+
+// ErrDeleteUnsupported 服务商不支持删除记录
+var ErrDeleteUnsupported = errors.New("provider does not support deleting records")
+
+// ErrRecordChanged 记录的当前值已不是本实例发布的地址
+var ErrRecordChanged = errors.New("record was changed")
+
+// DeleteRecord 删除 subDomain 下值为 ip 的 recordType 类型记录，服务商未实现 Deleter 时返回 ErrDeleteUnsupported
+func DeleteRecord(ctx context.Context, provider Provider, cfg config.Config, subDomain, recordType, ip string) error {
+	deleter, ok := provider.(Deleter)
+	if !ok {
+		return ErrDeleteUnsupported
+	}
+	return deleter.DeleteRecord(ctx, newRecord(cfg, subDomain, recordType, ip))
+}
+
+// recordChanged 返回记录已被修改、不再删除的错误
+func recordChanged(record Record, published []string) error {
+	return fmt.Errorf("%w: %s record %s now points to %s instead of %s", ErrRecordChanged,
+		record.Type, record.Name(), strings.Join(published, ","), strings.Join(record.AllValues(), ","))
+}
+
+// sameValues 判断 published 与记录的全部值是否为同一组地址，忽略顺序与 IPv6 地址的书写形式
+func sameValues(record Record, published []string) bool {
+	normalize := func(values []string) []string {
+		result := make([]string, len(values))
+		for i, value := range values {
+			if addr, err := netip.ParseAddr(value); err == nil {
+				value = addr.String()
+			}
+			result[i] = value
+		}
+		slices.Sort(result)
+		return slices.Compact(result)
+	}
+	return slices.Equal(normalize(record.AllValues()), normalize(published))
+}
+
+// NewProvider 根据配置创建对应的 DNS 服务商，cache 用于缓存需要记录ID的服务商的查询结果，可为 nil。
+// 各服务商的接口请求使用按 dns.resolver 与该服务商的超时(dns.providers 或 dns.timeout)创建的 HTTP 客户端。
+func NewProvider(cfg config.Config, cache *DNSCache) (Provider, error) {
+	client := NewHTTPClient(cfg.DNS.Resolver, time.Duration(cfg.ProviderTimeout())*time.Second)
+	switch cfg.DNS.Provider {
+	case "", "tencent":
+		return NewTencentProvider(cfg.Tencent, cache, client)
+	case "cloudflare":
+		return NewCloudflareProvider(cfg.Cloudflare, client), nil
+	case "aliyun":
+		return NewAliyunProvider(cfg.Aliyun, client)
+	case "duckdns":
+		return NewDuckDNSProvider(cfg.DuckDNS, client), nil
+	case "route53":
+		return NewRoute53Provider(cfg.Route53, client)
+	case "godaddy":
+		return NewGoDaddyProvider(cfg.GoDaddy, client), nil
+	case "namecheap":
+		return NewNamecheapProvider(cfg.Namecheap, client), nil
+	case "huawei":
+		return NewHuaweiProvider(cfg.Huawei, client), nil
+	default:
+		return nil, fmt.Errorf("unknown dns provider %q", cfg.DNS.Provider)
+	}
+}
+
+// clampTTL 将 ttl 限制在服务商允许的范围内，ttl 为 0 表示使用默认值不做处理
+func clampTTL(provider string, ttl, min, max int) int {
+	if ttl == 0 {
+		return 0
+	}
+	clamped := ttl
+	if clamped < min {
+		clamped = min
+	}
+	if clamped > max {
+		clamped = max
+	}
+	if clamped != ttl {
+		logrus.Warnf("TTL %d is outside the range allowed by %s (%d-%d), using %d", ttl, provider, min, max, clamped)
+	}
+	return clamped
+}
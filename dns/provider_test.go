@@ -0,0 +1,172 @@
+package dns
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ddns-ipv6/config"
+)
+
+// fakeAPI 记录服务商发出的请求并返回 respond 给出的响应体，respond 返回空字符串时响应 400
+type fakeAPI struct {
+	respond  func(r *http.Request) string
+	requests []string
+}
+
+func (f *fakeAPI) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+	}
+	f.requests = append(f.requests, r.Method+" "+r.URL.String()+" "+tencentAction(r)+" "+string(body))
+
+	status, respBody := http.StatusBadRequest, "{}"
+	if s := f.respond(r); s != "" {
+		status, respBody = http.StatusOK, s
+	}
+	contentType := "application/json"
+	if strings.HasPrefix(respBody, "<") {
+		contentType = "text/xml"
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       io.NopCloser(strings.NewReader(respBody)),
+		Request:    r,
+	}, nil
+}
+
+// tencentAction 返回腾讯云请求的接口名，SDK 写入的请求头键名未经规范化
+func tencentAction(r *http.Request) string {
+	if v := r.Header["X-TC-Action"]; len(v) > 0 {
+		return v[0]
+	}
+	return r.Header.Get("X-TC-Action")
+}
+
+// TestApexRecordName 检查各服务商为主域名记录("@")发送的记录名：查询时不存在，随后创建
+func TestApexRecordName(t *testing.T) {
+	// 部分环境设置的 CA 证书路径与自定义 http.Client 不兼容，AWS SDK 会拒绝加载配置
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	tests := []struct {
+		provider string
+		new      func(client *http.Client) (Provider, error)
+		// respond 对查询请求返回记录不存在的结果
+		respond func(r *http.Request) string
+		// want 依次出现在查询与创建请求中的记录名
+		want []string
+	}{
+		{
+			provider: "cloudflare",
+			new: func(client *http.Client) (Provider, error) {
+				return NewCloudflareProvider(config.Cloudflare{APIToken: "token", ZoneID: "zone"}, client), nil
+			},
+			respond: func(r *http.Request) string {
+				if r.Method == http.MethodGet {
+					return `{"success":true,"result":[]}`
+				}
+				return ""
+			},
+			want: []string{"name=example.com&", `"name":"example.com"`},
+		},
+		{
+			provider: "route53",
+			new: func(client *http.Client) (Provider, error) {
+				return NewRoute53Provider(config.Route53{AccessKeyId: "id", SecretAccessKey: "secret", HostedZoneID: "ZONE", Region: "us-east-1"}, client)
+			},
+			respond: func(r *http.Request) string {
+				if r.Method == http.MethodGet {
+					return `<ListResourceRecordSetsResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/"><ResourceRecordSets></ResourceRecordSets><IsTruncated>false</IsTruncated><MaxItems>1</MaxItems></ListResourceRecordSetsResponse>`
+				}
+				return ""
+			},
+			want: []string{"name=example.com.&", "<Name>example.com.</Name>"},
+		},
+		{
+			provider: "godaddy",
+			new: func(client *http.Client) (Provider, error) {
+				return NewGoDaddyProvider(config.GoDaddy{APIKey: "key", APISecret: "secret"}, client), nil
+			},
+			respond: func(r *http.Request) string {
+				if r.Method == http.MethodGet {
+					return `[]`
+				}
+				return ""
+			},
+			want: []string{"GET https://api.godaddy.com/v1/domains/example.com/records/AAAA/@ ", "/v1/domains/example.com/records/AAAA/@ "},
+		},
+		{
+			provider: "namecheap",
+			new: func(client *http.Client) (Provider, error) {
+				return NewNamecheapProvider(config.Namecheap{Password: "password"}, client), nil
+			},
+			respond: func(r *http.Request) string { return "" },
+			want:    []string{"domain=example.com&host=%40&"},
+		},
+		{
+			provider: "huawei",
+			new: func(client *http.Client) (Provider, error) {
+				return NewHuaweiProvider(config.Huawei{AccessKeyId: "id", SecretAccessKey: "secret", Region: "cn-north-4", ZoneID: "zone"}, client), nil
+			},
+			respond: func(r *http.Request) string {
+				if r.Method == http.MethodGet {
+					return `{"recordsets":[]}`
+				}
+				return ""
+			},
+			want: []string{"name=example.com.&", `"name":"example.com."`},
+		},
+		{
+			provider: "aliyun",
+			new: func(client *http.Client) (Provider, error) {
+				return NewAliyunProvider(config.Aliyun{AccessKeyId: "id", AccessKeySecret: "secret"}, client)
+			},
+			respond: func(r *http.Request) string {
+				if r.URL.Query().Get("Action") == "DescribeSubDomainRecords" {
+					return `{"TotalCount":0,"DomainRecords":{"Record":[]}}`
+				}
+				return ""
+			},
+			want: []string{"SubDomain=example.com&", "RR=%40&"},
+		},
+		{
+			provider: "tencent",
+			new: func(client *http.Client) (Provider, error) {
+				return NewTencentProvider(config.Tencent{SecretId: "id", SecretKey: "key"}, NewDNSCache(""), client)
+			},
+			respond: func(r *http.Request) string {
+				if tencentAction(r) == "DescribeRecordList" {
+					return `{"Response":{"Error":{"Code":"ResourceNotFound.NoDataOfRecord","Message":"no records"},"RequestId":"req"}}`
+				}
+				return ""
+			},
+			want: []string{`DescribeRecordList {"Domain":"example.com","Subdomain":"@"`, `"SubDomain":"@"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			api := &fakeAPI{respond: tt.respond}
+			provider, err := tt.new(&http.Client{Transport: api})
+			if err != nil {
+				t.Fatal(err)
+			}
+			record := Record{SubDomain: config.Apex, Domain: "example.com", Type: "AAAA", Value: "2400:3200::1", CreateIfMissing: true}
+			// 创建请求得到 400，只检查发出的请求
+			provider.UpdateRecord(context.Background(), record)
+
+			if len(api.requests) < len(tt.want) {
+				t.Fatalf("got %d requests, want at least %d: %q", len(api.requests), len(tt.want), api.requests)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(api.requests[i], want) {
+					t.Errorf("request %d = %q, want it to contain %q", i, api.requests[i], want)
+				}
+			}
+		})
+	}
+}
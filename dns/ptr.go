@@ -0,0 +1,103 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
+)
+
+// PTRUpdater 支持发布反向解析(PTR)记录的服务商，反向区域需已委派给该服务商并添加到账号中
+type PTRUpdater interface {
+	// UpdatePTR 将 ip 的 PTR 记录指向 hostname，记录不存在时创建；
+	// 账号中没有包含该地址的反向区域时返回 ErrPTRNotDelegated
+	UpdatePTR(ctx context.Context, ip, hostname string) error
+}
+
+// ErrPTRUnsupported 服务商不支持发布 PTR 记录
+var ErrPTRUnsupported = errors.New("provider does not support PTR records")
+
+// ErrPTRNotDelegated 账号中没有包含该地址的反向区域，反向解析通常由运营商管理，需先将其委派给该服务商
+var ErrPTRNotDelegated = errors.New("reverse zone is not delegated to the provider")
+
+// UpdatePTR 按服务商的重试配置将 ip 的 PTR 记录指向 hostname，服务商未实现 PTRUpdater 时返回 ErrPTRUnsupported。
+// 反向区域未委派时不再重试
+func UpdatePTR(ctx context.Context, provider Provider, cfg config.Config, ip, hostname string) error {
+	updater, ok := provider.(PTRUpdater)
+	if !ok {
+		return ErrPTRUnsupported
+	}
+	if cfg.DryRun {
+		httpclient.Logger(ctx).Printf("[dry-run] Would point PTR record of %s to %s", ip, hostname)
+		return nil
+	}
+
+	attempts := 0
+	var lastErr error
+	operation := func() error {
+		attempts++
+		lastErr = updater.UpdatePTR(ctx, ip, hostname)
+		if errors.Is(lastErr, ErrPTRNotDelegated) {
+			return backoff.Permanent(lastErr)
+		}
+		return lastErr
+	}
+	if err := backoff.Retry(operation, retryPolicy(ctx, cfg.ProviderRetry(), &lastErr)); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil && lastErr == nil {
+			lastErr = ctxErr
+		}
+		return fmt.Errorf("giving up after %d attempts: %w", attempts, lastErr)
+	}
+	return nil
+}
+
+// reverseName 返回地址的反向解析域名，如 2001:db8::1 对应 1.0.0.0...8.b.d.0.1.0.0.2.ip6.arpa
+func reverseName(ip string) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", err
+	}
+	addr = addr.Unmap().WithZone("")
+	b := addr.AsSlice()
+	var labels []string
+	if addr.Is4() {
+		for i := len(b) - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprint(b[i]))
+		}
+		return strings.Join(labels, ".") + ".in-addr.arpa", nil
+	}
+	const hex = "0123456789abcdef"
+	for i := len(b) - 1; i >= 0; i-- {
+		labels = append(labels, string(hex[b[i]&0x0f]), string(hex[b[i]>>4]))
+	}
+	return strings.Join(labels, ".") + ".ip6.arpa", nil
+}
+
+// reverseZone 从 zones 中选出包含 name 的最长(最具体)的反向区域，返回区域名与 name 在区域内的主机记录；
+// 没有匹配的区域时返回 ErrPTRNotDelegated
+func reverseZone(ip, name string, zones []string) (zone, subDomain string, err error) {
+	for _, z := range zones {
+		z = strings.TrimSuffix(strings.ToLower(z), ".")
+		if strings.HasSuffix(name, "."+z) && len(z) > len(zone) {
+			zone = z
+		}
+	}
+	if zone == "" {
+		return "", "", fmt.Errorf("%w: no zone containing %s found for %s", ErrPTRNotDelegated, name, ip)
+	}
+	return zone, strings.TrimSuffix(name, "."+zone), nil
+}
+
+// reverseSuffix 返回地址所属的反向解析顶级区域(ip6.arpa 或 in-addr.arpa)，用于查询账号中的反向区域
+func reverseSuffix(name string) string {
+	if strings.HasSuffix(name, ".in-addr.arpa") {
+		return "in-addr.arpa"
+	}
+	return "ip6.arpa"
+}
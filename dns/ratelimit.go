@@ -0,0 +1,85 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// 被限流时的等待时间，服务商未给出 Retry-After 时从 rateLimitDelay 开始翻倍，不超过 rateLimitMaxDelay
+const (
+	rateLimitDelay    = 30 * time.Second
+	rateLimitMaxDelay = 5 * time.Minute
+)
+
+// RateLimitError 服务商因请求过于频繁拒绝了请求，不代表配置或服务故障
+type RateLimitError struct {
+	Err error
+	// RetryAfter 服务商建议的等待时间，为 0 时表示未给出
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited: %v", e.Err)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// IsRateLimited 判断错误是否由服务商限流引起
+func IsRateLimited(err error) bool {
+	var rateLimitErr *RateLimitError
+	return errors.As(err, &rateLimitErr)
+}
+
+// parseRetryAfter 解析 Retry-After 响应头，支持秒数与 HTTP 日期两种格式
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// rateLimitBackOff 在上一次尝试被限流时使用更长的等待时间，其余情况沿用 BackOff 的重试节奏
+type rateLimitBackOff struct {
+	backoff.BackOff
+	// lastErr 指向最近一次尝试的错误
+	lastErr *error
+	// limited 连续被限流的次数
+	limited int
+}
+
+func (b *rateLimitBackOff) NextBackOff() time.Duration {
+	next := b.BackOff.NextBackOff()
+	var rateLimitErr *RateLimitError
+	if next == backoff.Stop || !errors.As(*b.lastErr, &rateLimitErr) {
+		b.limited = 0
+		return next
+	}
+
+	b.limited++
+	delay := rateLimitErr.RetryAfter
+	if delay == 0 {
+		delay = min(rateLimitDelay<<(b.limited-1), rateLimitMaxDelay)
+	}
+	delay = max(delay, next)
+	logrus.Warnf("Rate limited by DNS provider, backing off for %s before retrying", delay)
+	return delay
+}
+
+func (b *rateLimitBackOff) Reset() {
+	b.limited = 0
+	b.BackOff.Reset()
+}
@@ -0,0 +1,196 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/smithy-go"
+
+	"ddns-ipv6/config"
+)
+
+// Route 53 的 TTL 上限，未配置 TTL 时使用 route53DefaultTTL
+const (
+	route53MinTTL     = 1
+	route53MaxTTL     = 2147483647
+	route53DefaultTTL = 300
+)
+
+// route53SyncTimeout 等待变更同步到所有权威服务器的最长时间
+const route53SyncTimeout = 2 * time.Minute
+
+// Route53Provider AWS Route 53
+type Route53Provider struct {
+	client       *route53.Client
+	hostedZoneID string
+}
+
+// NewRoute53Provider 创建 Route 53 客户端，未配置访问密钥时使用 AWS 默认凭证链(环境变量、~/.aws、实例角色等)
+func NewRoute53Provider(cfg config.Route53, httpClient *http.Client) (*Route53Provider, error) {
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(cfg.Region), awsconfig.WithHTTPClient(httpClient)}
+	if cfg.AccessKeyId != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyId, cfg.SecretAccessKey, "")))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	return &Route53Provider{client: route53.NewFromConfig(awsCfg), hostedZoneID: cfg.HostedZoneID}, nil
+}
+
+// exists 查询托管区域中是否已有该名称与类型的记录
+func (p *Route53Provider) exists(ctx context.Context, record Record) (bool, error) {
+	set, err := p.find(ctx, record)
+	return set != nil, err
+}
+
+// find 查询托管区域中该名称与类型的记录集，不存在时返回 nil
+func (p *Route53Provider) find(ctx context.Context, record Record) (*types.ResourceRecordSet, error) {
+	name := record.Name() + "."
+	output, err := p.client.ListResourceRecordSets(ctx, &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(p.hostedZoneID),
+		StartRecordName: aws.String(name),
+		StartRecordType: types.RRType(record.Type),
+		MaxItems:        aws.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("route53: list records: %w", err)
+	}
+	// 结果从 StartRecordName 开始按名称排序，第一条不匹配即表示记录不存在
+	for _, set := range output.ResourceRecordSets {
+		if strings.EqualFold(aws.ToString(set.Name), name) && string(set.Type) == record.Type {
+			return &set, nil
+		}
+	}
+	return nil, nil
+}
+
+// UpdateRecord 以 UPSERT 方式更新域名解析记录，并等待变更生效；记录不存在且未开启 CreateIfMissing 时返回错误
+func (p *Route53Provider) UpdateRecord(ctx context.Context, record Record) error {
+	return route53Error(p.updateRecord(ctx, record))
+}
+
+// CheckRecord 查询记录是否存在
+func (p *Route53Provider) CheckRecord(ctx context.Context, record Record) (bool, error) {
+	exists, err := p.exists(ctx, record)
+	return exists, route53Error(err)
+}
+
+// DeleteRecord 删除记录集，记录集的当前值与 record 的全部值不一致时不删除；不等待变更同步
+func (p *Route53Provider) DeleteRecord(ctx context.Context, record Record) error {
+	set, err := p.find(ctx, record)
+	if err != nil || set == nil {
+		return route53Error(err)
+	}
+	var published []string
+	for _, value := range set.ResourceRecords {
+		published = append(published, aws.ToString(value.Value))
+	}
+	if !sameValues(record, published) {
+		return recordChanged(record, published)
+	}
+
+	// DELETE 要求与现有记录集完全一致，直接使用查询到的记录集
+	_, err = p.client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(p.hostedZoneID),
+		ChangeBatch: &types.ChangeBatch{
+			Comment: aws.String("ddns-ipv6"),
+			Changes: []types.Change{{Action: types.ChangeActionDelete, ResourceRecordSet: set}},
+		},
+	})
+	if err != nil {
+		return route53Error(fmt.Errorf("route53: delete record: %w", err))
+	}
+	return nil
+}
+
+func (p *Route53Provider) updateRecord(ctx context.Context, record Record) error {
+	ttl := clampTTL("route53", record.TTL, route53MinTTL, route53MaxTTL)
+	if ttl == 0 {
+		ttl = route53DefaultTTL
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, route53SyncTimeout)
+	defer cancel()
+
+	exists, err := p.exists(ctx, record)
+	if err != nil {
+		return err
+	}
+	if !exists && !record.CreateIfMissing {
+		return recordNotFound(record)
+	}
+
+	output, err := p.client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(p.hostedZoneID),
+		ChangeBatch: &types.ChangeBatch{
+			Comment: aws.String("ddns-ipv6"),
+			Changes: []types.Change{{
+				Action: types.ChangeActionUpsert,
+				ResourceRecordSet: &types.ResourceRecordSet{
+					Name:            aws.String(record.Name() + "."),
+					Type:            types.RRType(record.Type),
+					TTL:             aws.Int64(int64(ttl)),
+					ResourceRecords: route53Values(record),
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("route53: change record: %w", err)
+	}
+	if !exists {
+		logBootstrapped(record)
+	}
+	if output.ChangeInfo == nil || output.ChangeInfo.Status == types.ChangeStatusInsync {
+		return nil
+	}
+
+	// 变更处于 PENDING 状态，等待同步完成，超时或失败时返回错误以触发重试
+	waiter := route53.NewResourceRecordSetsChangedWaiter(p.client)
+	err = waiter.Wait(ctx, &route53.GetChangeInput{Id: output.ChangeInfo.Id}, route53SyncTimeout)
+	if err != nil {
+		return fmt.Errorf("route53: change %s did not sync: %w", aws.ToString(output.ChangeInfo.Id), err)
+	}
+	return nil
+}
+
+// route53Values 返回记录集的全部值
+func route53Values(record Record) []types.ResourceRecord {
+	var values []types.ResourceRecord
+	for _, value := range record.AllValues() {
+		values = append(values, types.ResourceRecord{Value: aws.String(value)})
+	}
+	return values
+}
+
+// route53AuthCodes 表示凭证无效、过期或权限不足的错误码
+var route53AuthCodes = map[string]bool{
+	"AccessDenied":                true,
+	"AccessDeniedException":       true,
+	"ExpiredToken":                true,
+	"IncompleteSignature":         true,
+	"InvalidClientTokenId":        true,
+	"SignatureDoesNotMatch":       true,
+	"UnrecognizedClientException": true,
+}
+
+// route53Error 将凭证相关的接口错误标记为凭证错误
+func route53Error(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && route53AuthCodes[apiErr.ErrorCode()] && !IsAuthError(err) {
+		return authError(err)
+	}
+	return err
+}
@@ -0,0 +1,391 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ddns-ipv6/config"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/tencentcloud/tencentcloud-sdk-go-intl-en/tencentcloud/common"
+	sdkerrors "github.com/tencentcloud/tencentcloud-sdk-go-intl-en/tencentcloud/common/errors"
+	"github.com/tencentcloud/tencentcloud-sdk-go-intl-en/tencentcloud/common/profile"
+	dnspod "github.com/tencentcloud/tencentcloud-sdk-go-intl-en/tencentcloud/dnspod/v20210323"
+)
+
+// DNSPod 允许的 TTL 范围，实际下限取决于套餐(免费版为 600)
+const (
+	tencentMinTTL = 1
+	tencentMaxTTL = 604800
+)
+
+// TencentProvider 腾讯云 DNSPod
+type TencentProvider struct {
+	client *dnspod.Client
+	// cache 缓存记录ID，为 nil 时每次更新前都查询
+	cache *DNSCache
+}
+
+func NewTencentProvider(cfg config.Tencent, cache *DNSCache, httpClient *http.Client) (*TencentProvider, error) {
+	credential := common.NewCredential(
+		cfg.SecretId,
+		cfg.SecretKey,
+	)
+	cpf := profile.NewClientProfile()
+	if httpClient.Timeout > 0 {
+		cpf.HttpProfile.ReqTimeout = int(httpClient.Timeout / time.Second)
+	}
+	client, err := dnspod.NewClient(credential, cfg.Region, cpf)
+	if err != nil {
+		return nil, err
+	}
+	client.WithHttpTransport(httpClient.Transport)
+	return &TencentProvider{client: client, cache: cache}, nil
+}
+
+// UpdateRecord 更新域名解析记录，记录不存在且开启 CreateIfMissing 时创建
+func (p *TencentProvider) UpdateRecord(ctx context.Context, record Record) error {
+	return tencentError(p.updateRecord(ctx, record))
+}
+
+// CheckRecord 查询记录是否存在
+func (p *TencentProvider) CheckRecord(ctx context.Context, record Record) (bool, error) {
+	recordID, err := p.findRecord(ctx, record)
+	return recordID != nil, tencentError(err)
+}
+
+func (p *TencentProvider) updateRecord(ctx context.Context, record Record) error {
+	ttl := clampTTL("tencent", record.TTL, tencentMinTTL, tencentMaxTTL)
+
+	// 优先使用缓存的记录ID，记录已被删除时清除缓存并重新查询
+	if recordID, ok := p.cachedRecordID(record); ok {
+		err := p.modifyRecord(ctx, record, recordID, ttl)
+		if !isTencentRecordNotFound(err) {
+			return err
+		}
+		logrus.Printf("Cached record ID %d for %s is no longer valid, looking it up again", recordID, record.Name())
+		p.setCachedRecordID(record, nil)
+	}
+
+	recordID, err := p.findRecord(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	if recordID == nil {
+		if !record.CreateIfMissing {
+			return recordNotFound(record)
+		}
+		createRequest := dnspod.NewCreateRecordRequest()
+		createRequest.Domain = common.StringPtr(record.Domain)
+		createRequest.SubDomain = common.StringPtr(record.SubDomain)
+		createRequest.RecordType = common.StringPtr(record.Type)
+		createRequest.RecordLine = common.StringPtr("默认")
+		createRequest.Value = common.StringPtr(record.Value)
+		if ttl > 0 {
+			createRequest.TTL = common.Uint64Ptr(uint64(ttl))
+		}
+		createResponse, err := p.client.CreateRecordWithContext(ctx, createRequest)
+		if err != nil {
+			return err
+		}
+		logBootstrapped(record)
+		p.setCachedRecordID(record, createResponse.Response.RecordId)
+		return nil
+	}
+
+	p.setCachedRecordID(record, recordID)
+	return p.modifyRecord(ctx, record, *recordID, ttl)
+}
+
+// modifyRecord 修改指定ID的记录
+func (p *TencentProvider) modifyRecord(ctx context.Context, record Record, recordID uint64, ttl int) error {
+	modifyRequest := dnspod.NewModifyRecordRequest()
+	modifyRequest.Domain = common.StringPtr(record.Domain)
+	modifyRequest.RecordId = common.Uint64Ptr(recordID)
+	modifyRequest.SubDomain = common.StringPtr(record.SubDomain)
+	modifyRequest.RecordType = common.StringPtr(record.Type)
+	modifyRequest.RecordLine = common.StringPtr("默认")
+	modifyRequest.Value = common.StringPtr(record.Value)
+	if ttl > 0 {
+		modifyRequest.TTL = common.Uint64Ptr(uint64(ttl))
+	}
+
+	_, err := p.client.ModifyRecordWithContext(ctx, modifyRequest)
+	return err
+}
+
+func (p *TencentProvider) cachedRecordID(record Record) (uint64, bool) {
+	if p.cache == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(p.cache.RecordID(record.Type, record.Name()), 10, 64)
+	return id, err == nil
+}
+
+func (p *TencentProvider) setCachedRecordID(record Record, recordID *uint64) {
+	if p.cache == nil {
+		return
+	}
+	id := ""
+	if recordID != nil {
+		id = strconv.FormatUint(*recordID, 10)
+	}
+	p.cache.SetRecordID(record.Type, record.Name(), id)
+}
+
+// DeleteRecord 删除记录，记录的当前值不是 record.Value 时不删除
+func (p *TencentProvider) DeleteRecord(ctx context.Context, record Record) error {
+	item, err := p.findRecordItem(ctx, record)
+	if err != nil || item == nil {
+		return tencentError(err)
+	}
+	if value := *item.Value; !sameValues(record, []string{value}) {
+		return recordChanged(record, []string{value})
+	}
+
+	request := dnspod.NewDeleteRecordRequest()
+	request.Domain = common.StringPtr(record.Domain)
+	request.RecordId = item.RecordId
+	if _, err := p.client.DeleteRecordWithContext(ctx, request); err != nil && !isTencentRecordNotFound(err) {
+		return tencentError(err)
+	}
+	p.setCachedRecordID(record, nil)
+	return nil
+}
+
+// UpdatePTR 在账号中包含该地址的最具体的反向区域内创建或更新 PTR 记录
+func (p *TencentProvider) UpdatePTR(ctx context.Context, ip, hostname string) error {
+	name, err := reverseName(ip)
+	if err != nil {
+		return backoff.Permanent(err)
+	}
+
+	request := dnspod.NewDescribeDomainListRequest()
+	request.Keyword = common.StringPtr(reverseSuffix(name))
+	response, err := p.client.DescribeDomainListWithContext(ctx, request)
+	var zones []string
+	switch {
+	case isTencentNoData(err):
+		// 账号中没有匹配的域名
+	case err != nil:
+		return tencentError(err)
+	default:
+		for _, domain := range response.Response.DomainList {
+			if domain.Name != nil {
+				zones = append(zones, *domain.Name)
+			}
+		}
+	}
+	zone, subDomain, err := reverseZone(ip, name, zones)
+	if err != nil {
+		return err
+	}
+
+	// DNSPod 的域名类记录值以 "." 结尾
+	record := Record{SubDomain: subDomain, Domain: zone, Type: "PTR", Value: strings.TrimSuffix(hostname, ".") + ".", CreateIfMissing: true}
+	return tencentError(p.updateRecord(ctx, record))
+}
+
+// findRecord 查找子域名下指定类型的记录ID，不存在时返回 nil
+func (p *TencentProvider) findRecord(ctx context.Context, record Record) (*uint64, error) {
+	item, err := p.findRecordItem(ctx, record)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	return item.RecordId, nil
+}
+
+// findRecordItem 查找子域名下指定类型的记录，不存在时返回 nil
+func (p *TencentProvider) findRecordItem(ctx context.Context, record Record) (*dnspod.RecordListItem, error) {
+	listRequest := dnspod.NewDescribeRecordListRequest()
+	listRequest.Domain = common.StringPtr(record.Domain)
+	listRequest.Subdomain = common.StringPtr(record.SubDomain)
+	listRequest.RecordType = common.StringPtr(record.Type)
+
+	listResponse, err := p.client.DescribeRecordListWithContext(ctx, listRequest)
+	// 子域名下没有任何记录时接口返回 NoDataOfRecord
+	if isTencentRecordNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range listResponse.Response.RecordList {
+		if *item.Type == record.Type && *item.Name == record.SubDomain {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
+
+// tencentError 将限流错误码(RequestLimitExceeded 及其子错误码)转换为 RateLimitError
+func tencentError(err error) error {
+	var sdkErr *sdkerrors.TencentCloudSDKError
+	if !errors.As(err, &sdkErr) || IsRateLimited(err) || IsAuthError(err) {
+		return err
+	}
+	switch {
+	case strings.HasPrefix(sdkErr.Code, dnspod.REQUESTLIMITEXCEEDED):
+		return &RateLimitError{Err: err}
+	case strings.HasPrefix(sdkErr.Code, "AuthFailure"), strings.HasPrefix(sdkErr.Code, "UnauthorizedOperation"):
+		// 密钥不存在、已禁用、签名错误或子账号未授权
+		return authError(err)
+	}
+	return err
+}
+
+// isTencentRecordNotFound 判断接口错误是否表示记录不存在
+func isTencentRecordNotFound(err error) bool {
+	var sdkErr *sdkerrors.TencentCloudSDKError
+	if !errors.As(err, &sdkErr) {
+		return false
+	}
+	return sdkErr.Code == dnspod.RESOURCENOTFOUND_NODATAOFRECORD || sdkErr.Code == dnspod.INVALIDPARAMETER_RECORDIDINVALID
+}
+
+// isTencentNoData 判断接口错误是否表示查询结果为空
+func isTencentNoData(err error) bool {
+	var sdkErr *sdkerrors.TencentCloudSDKError
+	return errors.As(err, &sdkErr) && strings.HasPrefix(sdkErr.Code, "ResourceNotFound.NoData")
+}
+
+// tencentBatchKey 可合并为一次批量修改的记录分组
+type tencentBatchKey struct {
+	domain, recordType, value string
+	ttl                       int
+}
+
+// UpdateRecords 通过 ModifyRecordBatch 批量修改同一域名下指向相同地址的记录，
+// 不存在的记录逐条创建，批量结果中的失败按记录分别返回
+func (p *TencentProvider) UpdateRecords(ctx context.Context, records []Record) []error {
+	errs := make([]error, len(records))
+	groups := make(map[tencentBatchKey][]int)
+	var keys []tencentBatchKey
+	for i, record := range records {
+		key := tencentBatchKey{record.Domain, record.Type, record.Value, record.TTL}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, key := range keys {
+		var ids []uint64
+		var indexes []int
+		for _, i := range groups[key] {
+			recordID, err := p.recordID(ctx, records[i])
+			switch {
+			case err != nil:
+				errs[i] = tencentError(err)
+			case recordID == nil:
+				errs[i] = p.UpdateRecord(ctx, records[i])
+			default:
+				ids = append(ids, *recordID)
+				indexes = append(indexes, i)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		ttl := clampTTL("tencent", key.ttl, tencentMinTTL, tencentMaxTTL)
+		batchErrs, err := p.modifyRecordBatch(ctx, ids, key.value, ttl)
+		if isTencentRecordNotFound(err) {
+			// 缓存的记录ID已失效，逐条更新以便重新查询
+			logrus.Printf("Batch update of %d records failed with an invalid record ID, updating them one by one", len(ids))
+			for _, i := range indexes {
+				p.setCachedRecordID(records[i], nil)
+				errs[i] = p.UpdateRecord(ctx, records[i])
+			}
+			continue
+		}
+		for j, i := range indexes {
+			if err != nil {
+				errs[i] = tencentError(err)
+			} else {
+				errs[i] = batchErrs[j]
+			}
+		}
+	}
+	return errs
+}
+
+// recordID 返回缓存或查询到的记录ID，记录不存在时返回 nil
+func (p *TencentProvider) recordID(ctx context.Context, record Record) (*uint64, error) {
+	if recordID, ok := p.cachedRecordID(record); ok {
+		return &recordID, nil
+	}
+	recordID, err := p.findRecord(ctx, record)
+	if err != nil || recordID == nil {
+		return nil, err
+	}
+	p.setCachedRecordID(record, recordID)
+	return recordID, nil
+}
+
+// modifyRecordBatch 将 ids 对应的记录值批量修改为 value，ttl 大于 0 时再批量修改 TTL。
+// 请求整体失败时返回 err，否则返回与 ids 一一对应的单条记录错误。
+func (p *TencentProvider) modifyRecordBatch(ctx context.Context, ids []uint64, value string, ttl int) ([]error, error) {
+	changes := [][2]string{{"value", value}}
+	if ttl > 0 {
+		changes = append(changes, [2]string{"ttl", strconv.Itoa(ttl)})
+	}
+
+	errs := make([]error, len(ids))
+	for _, change := range changes {
+		request := dnspod.NewModifyRecordBatchRequest()
+		request.RecordIdList = common.Uint64Ptrs(ids)
+		request.Change = common.StringPtr(change[0])
+		request.ChangeTo = common.StringPtr(change[1])
+
+		response, err := p.client.ModifyRecordBatchWithContext(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		for j, err := range tencentBatchErrors(response.Response, ids) {
+			if errs[j] == nil {
+				errs[j] = err
+			}
+		}
+	}
+	return errs, nil
+}
+
+// tencentBatchErrors 从批量修改结果中取出每条记录的错误，未在结果中单独列出的记录视为成功
+func tencentBatchErrors(response *dnspod.ModifyRecordBatchResponseParams, ids []uint64) []error {
+	failures := make(map[uint64]string)
+	for _, detail := range response.DetailList {
+		// 域名级别的失败没有逐条结果，本批记录同属一个域名，全部视为失败
+		if len(detail.RecordList) == 0 && detail.ErrMsg != nil && *detail.ErrMsg != "" {
+			for _, id := range ids {
+				failures[id] = *detail.ErrMsg
+			}
+		}
+		for _, item := range detail.RecordList {
+			if item.RecordId == nil {
+				continue
+			}
+			if item.ErrMsg != nil && *item.ErrMsg != "" {
+				failures[*item.RecordId] = *item.ErrMsg
+			} else if detail.ErrMsg != nil && *detail.ErrMsg != "" {
+				failures[*item.RecordId] = *detail.ErrMsg
+			}
+		}
+	}
+
+	errs := make([]error, len(ids))
+	for j, id := range ids {
+		if msg, ok := failures[id]; ok {
+			errs[j] = fmt.Errorf("batch update of record %d failed: %s", id, msg)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,107 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"ddns-ipv6/config"
+)
+
+// VerifyRecord 解析 name 的记录并确认包含 ip(逗号分隔的多个地址时需全部包含)，在 cfg.Attempts 次内未生效或 ctx 取消时返回错误
+func VerifyRecord(ctx context.Context, name, recordType, ip string, cfg config.Verify) error {
+	var expected []net.IP
+	for _, value := range strings.Split(ip, ",") {
+		expected = append(expected, net.ParseIP(value))
+	}
+	network := "ip6"
+	if recordType == "A" {
+		network = "ip4"
+	}
+
+	resolver := newResolver(cfg.Resolver)
+	delay := time.Duration(cfg.Delay) * time.Second
+
+	var last []net.IP
+	var lastErr error
+	for attempt := 1; attempt <= cfg.Attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return fmt.Errorf("verify %s record %s: %w", recordType, name, ctx.Err())
+			}
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		last, lastErr = resolver.LookupIP(lookupCtx, network, name)
+		cancel()
+		if lastErr != nil {
+			continue
+		}
+		if containsAll(last, expected) {
+			return nil
+		}
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("verify %s record %s: %v", recordType, name, lastErr)
+	}
+	return fmt.Errorf("verify %s record %s: expected %s, resolved %v after %d attempts", recordType, name, ip, last, cfg.Attempts)
+}
+
+// containsAll 判断 addrs 是否包含 expected 中的全部地址
+func containsAll(addrs, expected []net.IP) bool {
+	for _, want := range expected {
+		if !slices.ContainsFunc(addrs, want.Equal) {
+			return false
+		}
+	}
+	return true
+}
+
+// ResolveRecord 解析 name 当前发布的 recordType 记录值，记录不存在时返回空切片，resolverAddr 为空时使用系统解析器
+func ResolveRecord(ctx context.Context, name, recordType, resolverAddr string) ([]string, error) {
+	network := "ip6"
+	if recordType == "A" {
+		network = "ip4"
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addrs, err := newResolver(resolverAddr).LookupIP(ctx, network, name)
+	var dnsErr *net.DNSError
+	var addrErr *net.AddrError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound || errors.As(err, &addrErr) {
+		// 域名不存在或只有其他类型的记录
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(addrs))
+	for i, addr := range addrs {
+		result[i] = addr.String()
+	}
+	return result, nil
+}
+
+// newResolver 返回使用指定 DNS 服务器的解析器，addr 为空时使用系统解析器
+func newResolver(addr string) *net.Resolver {
+	if addr == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
@@ -5,27 +5,123 @@ import (
 	"time"
 )
 
+// recentChangesSize 保留的最近地址变更条数
+const recentChangesSize = 10
+
 type HealthCheck struct {
 	LastSuccess time.Time
 	Errors      int
+	LastError   string
+	Successes   int
+	// Detections 各地址类型(ipv6/ipv4)最近一次的检测结果
+	Detections map[string]Detection
+	// Started 创建时间，即进程启动时间
+	Started time.Time
+	// Changes 启动后已更新到 DNS 的地址变更次数
+	Changes int
+	// Degraded 记录已由备用服务商更新、主服务商尚未同步的原因，为空表示未降级
+	Degraded string
+	// ErrorThreshold 连续错误数达到该值时视为不健康，随配置重新加载更新
+	ErrorThreshold int
+	// recent 最近的地址变更，环形缓冲区，next 为下一条写入的位置
+	recent []Change
+	next   int
 	sync.RWMutex
 }
 
+// Change 一次已更新到 DNS 的地址变更
+type Change struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	IP   string    `json:"ip"`
+}
+
+// Detection 一次本地地址检测的结果
+type Detection struct {
+	IP      string    `json:"ip,omitempty"`
+	Method  string    `json:"method"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
 func NewHealthCheck() *HealthCheck {
-	return &HealthCheck{}
+	return &HealthCheck{Detections: make(map[string]Detection), Started: time.Now()}
+}
+
+// RecordDetection 记录一次地址检测结果，不影响连续错误数
+func (h *HealthCheck) RecordDetection(family, method, ip string, err error) {
+	h.Lock()
+	defer h.Unlock()
+	d := Detection{IP: ip, Method: method, Success: err == nil, Time: time.Now()}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	h.Detections[family] = d
 }
 
-func (h *HealthCheck) RecordSuccess() {
+// RecordSuccess 记录一次成功并清零连续错误数，返回清零前的连续错误数，
+// 调用方可据此判断是否从不健康状态恢复
+func (h *HealthCheck) RecordSuccess() int {
 	h.Lock()
 	defer h.Unlock()
+	previous := h.Errors
 	h.LastSuccess = time.Now()
 	h.Errors = 0
+	h.LastError = ""
+	h.Successes++
+	return previous
+}
+
+// RecordChange 记录一次已更新到 DNS 的地址变更
+func (h *HealthCheck) RecordChange(recordType, ip string) {
+	h.Lock()
+	defer h.Unlock()
+	h.Changes++
+	change := Change{Time: time.Now(), Type: recordType, IP: ip}
+	if len(h.recent) < recentChangesSize {
+		h.recent = append(h.recent, change)
+		return
+	}
+	h.recent[h.next] = change
+	h.next = (h.next + 1) % recentChangesSize
+}
+
+// recentChanges 按时间顺序返回最近的地址变更，调用方需持有读锁
+func (h *HealthCheck) recentChanges() []Change {
+	changes := make([]Change, 0, len(h.recent))
+	changes = append(changes, h.recent[h.next:]...)
+	return append(changes, h.recent[:h.next]...)
+}
+
+// SetDegraded 设置降级原因，reason 为空时清除降级状态；降级不影响健康状态与连续错误数
+func (h *HealthCheck) SetDegraded(reason string) {
+	h.Lock()
+	defer h.Unlock()
+	h.Degraded = reason
+}
+
+// SetErrorThreshold 设置视为不健康的连续错误数，启动与重新加载配置时调用
+func (h *HealthCheck) SetErrorThreshold(threshold int) {
+	h.Lock()
+	defer h.Unlock()
+	h.ErrorThreshold = threshold
+}
+
+// Healthy 判断连续错误数是否仍低于阈值
+func (h *HealthCheck) Healthy() bool {
+	h.RLock()
+	defer h.RUnlock()
+	return h.Errors < h.ErrorThreshold
 }
 
-func (h *HealthCheck) RecordError() int {
+func (h *HealthCheck) RecordError(err error) int {
 	h.Lock()
 	defer h.Unlock()
 	h.Errors++
+	if err != nil {
+		h.LastError = err.Error()
+	}
 	return h.Errors
 }
 
@@ -0,0 +1,203 @@
+package health
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/metrics"
+)
+
+// IPSource 提供各记录类型当前已发布的地址
+type IPSource interface {
+	GetIP(recordType string) (string, time.Time)
+}
+
+// Target 一个更新目标的健康状态与已发布地址
+type Target struct {
+	// Name 目标名称，只有一个目标时可为空
+	Name  string
+	Check *HealthCheck
+	IPs   IPSource
+}
+
+// Status /status 接口返回的内容
+type Status struct {
+	Name              string    `json:"name,omitempty"`
+	Healthy           bool      `json:"healthy"`
+	IPv6              string    `json:"ipv6,omitempty"`
+	IPv4              string    `json:"ipv4,omitempty"`
+	LastUpdate        time.Time `json:"lastUpdate"`
+	LastSuccess       time.Time `json:"lastSuccess"`
+	ConsecutiveErrors int       `json:"consecutiveErrors"`
+	TotalSuccesses    int       `json:"totalSuccesses"`
+	LastError         string    `json:"lastError,omitempty"`
+	// Degraded 记录已由备用服务商更新但主服务商尚未同步，DegradedReason 为具体原因
+	Degraded       bool   `json:"degraded"`
+	DegradedReason string `json:"degradedReason,omitempty"`
+	// Detected 最近一次检测到的本地地址，首次更新完成前即可查看
+	Detected map[string]Detection `json:"detected,omitempty"`
+	// StartedAt/Uptime 启动时间与已运行时长
+	StartedAt time.Time `json:"startedAt"`
+	Uptime    string    `json:"uptime"`
+	Changes   Changes   `json:"changes"`
+	// Proxy 反向代理各监听地址的连接数，由所有目标共用
+	Proxy any `json:"proxy,omitempty"`
+}
+
+// Changes 启动以来的地址变更统计
+type Changes struct {
+	// Total 已更新到 DNS 的地址变更次数，含启动后的首次更新
+	Total      int        `json:"total"`
+	LastChange *time.Time `json:"lastChange,omitempty"`
+	// Recent 最近的变更，按时间先后排列
+	Recent []Change `json:"recent"`
+}
+
+// ActionFunc 执行一个管理操作，返回可编码为 JSON 的结果
+type ActionFunc func() (any, error)
+
+// Actions 需要令牌才能调用的管理操作
+type Actions struct {
+	// Reload 重新加载配置，返回变更摘要；配置无效时返回错误且不影响当前配置
+	Reload ActionFunc
+	// Update 立即执行一次检测与更新，返回各目标的结果；有目标失败时同时返回结果与错误
+	Update ActionFunc
+}
+
+// StartServer 在后台启动健康检查服务，任一目标的连续错误数达到阈值时 /healthz 返回 503。
+// 只有一个目标时 /status 返回该目标的状态，多个目标时返回各目标状态的列表。
+// 配置了 ReloadToken、UpdateToken 时分别提供 POST /reload 与 POST /update。
+// proxyStats 非 nil 时其结果作为 proxy 字段附在 /status 中。
+// 监听地址在返回前绑定，绑定失败时返回错误；返回的 server 可通过 Shutdown 关闭。
+func StartServer(cfg config.Health, targets []Target, actions Actions, proxyStats func() any) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		var unhealthy []string
+		for _, t := range targets {
+			if !t.Check.Healthy() {
+				unhealthy = append(unhealthy, t.Name)
+			}
+		}
+		if len(unhealthy) > 0 {
+			message := "unhealthy"
+			if len(targets) > 1 {
+				message += ": " + strings.Join(unhealthy, ", ")
+			}
+			http.Error(w, message, http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var proxy any
+		if proxyStats != nil {
+			proxy = proxyStats()
+		}
+		if len(targets) == 1 {
+			s := targets[0].status()
+			s.Proxy = proxy
+			json.NewEncoder(w).Encode(s)
+			return
+		}
+		statuses := make([]Status, len(targets))
+		for i, t := range targets {
+			statuses[i] = t.status()
+			statuses[i].Proxy = proxy
+		}
+		json.NewEncoder(w).Encode(statuses)
+	})
+	if cfg.EnableMetrics {
+		mux.Handle("/metrics", metrics.Handler())
+	}
+	if cfg.ReloadToken != "" && actions.Reload != nil {
+		mux.Handle("/reload", actionHandler(cfg.ReloadToken, actions.Reload, http.StatusBadRequest))
+	}
+	if cfg.UpdateToken != "" && actions.Update != nil {
+		mux.Handle("/update", actionHandler(cfg.UpdateToken, actions.Update, http.StatusInternalServerError))
+	}
+
+	server := &http.Server{Addr: cfg.ListenAddr, Handler: mux}
+	ln, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Printf("Starting health server on %s", cfg.ListenAddr)
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("Health server on %s stopped: %v", cfg.ListenAddr, err)
+		}
+	}()
+	return server, nil
+}
+
+// actionHandler 校验令牌后执行 action，成功返回 200 与结果；
+// 失败返回 failStatus，有结果时返回结果，否则返回错误信息
+func actionHandler(token string, action ActionFunc, failStatus int) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			logrus.Warnf("Rejected %s request from %s: invalid token", r.URL.Path, r.RemoteAddr)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		result, err := action()
+		if err != nil {
+			w.WriteHeader(failStatus)
+			if result == nil {
+				result = map[string]string{"error": err.Error()}
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+func (t Target) status() Status {
+	h, ips := t.Check, t.IPs
+	h.RLock()
+	s := Status{
+		Name:              t.Name,
+		Healthy:           h.Errors < h.ErrorThreshold,
+		LastSuccess:       h.LastSuccess,
+		ConsecutiveErrors: h.Errors,
+		TotalSuccesses:    h.Successes,
+		LastError:         h.LastError,
+		Degraded:          h.Degraded != "",
+		DegradedReason:    h.Degraded,
+		Detected:          make(map[string]Detection, len(h.Detections)),
+		StartedAt:         h.Started,
+		Uptime:            time.Since(h.Started).Round(time.Second).String(),
+		Changes:           Changes{Total: h.Changes, Recent: h.recentChanges()},
+	}
+	for family, d := range h.Detections {
+		s.Detected[family] = d
+	}
+	if n := len(s.Changes.Recent); n > 0 {
+		s.Changes.LastChange = &s.Changes.Recent[n-1].Time
+	}
+	h.RUnlock()
+
+	var v4Update time.Time
+	s.IPv6, s.LastUpdate = ips.GetIP("AAAA")
+	s.IPv4, v4Update = ips.GetIP("A")
+	if v4Update.After(s.LastUpdate) {
+		s.LastUpdate = v4Update
+	}
+	return s
+}
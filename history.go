@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// historyEntry 历史文件中的一行，记录一次确认生效的地址变更
+type historyEntry struct {
+	Time    time.Time `json:"time"`
+	Target  string    `json:"target,omitempty"`
+	Type    string    `json:"type"`
+	OldIP   string    `json:"oldIP"`
+	NewIP   string    `json:"newIP"`
+	Records []string  `json:"records"`
+}
+
+// historyMu 多个目标可能写同一个历史文件，串行写入避免行交错
+var historyMu sync.Mutex
+
+// appendHistory 以 JSON Lines 格式向 path 追加一条记录，文件不存在时创建
+func appendHistory(path string, entry historyEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// hookEnv 传递给更新钩子的变更信息
+type hookEnv struct {
+	event     string
+	target    string
+	family    ipFamily
+	oldIP     string
+	ip        string
+	hostnames []string
+}
+
+// runHook 通过 shell 执行钩子命令并等待其退出，退出码非零或超时返回错误，错误中附带 stderr 输出
+func runHook(ctx context.Context, command string, timeout time.Duration, env hookEnv) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	cmd.Env = append(os.Environ(),
+		"DDNS_EVENT="+env.event,
+		"DDNS_TARGET="+env.target,
+		"DDNS_TYPE="+env.family.recordType,
+		"DDNS_IP="+env.ip,
+		"DDNS_OLD_IP="+env.oldIP,
+		"DDNS_HOSTNAME="+strings.Join(env.hostnames, ","),
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// 超时只能结束 shell 本身，其子进程仍占用 stderr 时不再等待
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s hook timed out after %s", env.event, timeout)
+	}
+	if err != nil {
+		if output := bytes.TrimSpace(stderr.Bytes()); len(output) > 0 {
+			return fmt.Errorf("%s hook: %w: %s", env.event, err, output)
+		}
+		return fmt.Errorf("%s hook: %w", env.event, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Version 程序版本，可在构建时通过 -ldflags "-X ddns-ipv6/httpclient.Version=v1.2.3" 指定，
+// 未指定时使用模块版本信息
+var Version = ""
+
+// RequestIDHeader 携带检测周期请求ID的请求头
+const RequestIDHeader = "X-Request-ID"
+
+// userAgent 当前使用的 User-Agent，为空时使用 DefaultUserAgent
+var userAgent atomic.Value
+
+// DefaultUserAgent 返回默认的 User-Agent，形如 ddns-ipv6/v1.2.3
+func DefaultUserAgent() string {
+	version := Version
+	if version == "" {
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		} else {
+			version = "dev"
+		}
+	}
+	return "ddns-ipv6/" + version
+}
+
+// SetUserAgent 设置所有出站请求使用的 User-Agent，为空时恢复默认值
+func SetUserAgent(ua string) {
+	userAgent.Store(ua)
+}
+
+// UserAgent 返回出站请求使用的 User-Agent
+func UserAgent() string {
+	if ua, _ := userAgent.Load().(string); ua != "" {
+		return ua
+	}
+	return DefaultUserAgent()
+}
+
+type requestIDKey struct{}
+
+// NewRequestID 生成一个随机的请求ID
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID 返回携带请求ID的 ctx，经由本包客户端发出的请求会带上该ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回 ctx 中的请求ID，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Logger 返回带有 ctx 中请求ID字段的日志记录器，便于按请求ID追踪一次完整的检测与更新
+func Logger(ctx context.Context) *logrus.Entry {
+	if id := RequestID(ctx); id != "" {
+		return logrus.WithField("request_id", id)
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// New 创建出站 HTTP 客户端，请求统一带上 User-Agent 与请求ID
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: Wrap(http.DefaultTransport), Timeout: timeout}
+}
+
+// Wrap 为 base 发出的请求统一设置 User-Agent，ctx 中有请求ID时设置 X-Request-ID
+func Wrap(base http.RoundTripper) http.RoundTripper {
+	return &headerTransport{base: base}
+}
+
+type headerTransport struct {
+	base http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper 不应修改传入的请求
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", UserAgent())
+	if id := RequestID(req.Context()); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	return t.base.RoundTrip(req)
+}
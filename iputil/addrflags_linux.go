@@ -0,0 +1,46 @@
+package iputil
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ifaFlagsFile 内核导出的 IPv6 地址列表，每行包含地址及其标志位
+const ifaFlagsFile = "/proc/net/if_inet6"
+
+// readAddrFlags 读取各 IPv6 地址的 IFA_F_* 标志位
+func readAddrFlags() (map[string]uint32, error) {
+	f, err := os.Open(ifaFlagsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	flags := make(map[string]uint32)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 格式: 地址 网卡序号 前缀长度 作用域 标志 网卡名
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[0])
+		if err != nil || len(raw) != net.IPv6len {
+			continue
+		}
+		flag, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil {
+			continue
+		}
+		flags[net.IP(raw).String()] = uint32(flag)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %v", ifaFlagsFile, err)
+	}
+	return flags, nil
+}
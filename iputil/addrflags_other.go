@@ -0,0 +1,10 @@
+//go:build !linux
+
+package iputil
+
+import "errors"
+
+// readAddrFlags 当前平台无法读取地址标志位
+func readAddrFlags() (map[string]uint32, error) {
+	return nil, errors.New("address flags are not available on this platform")
+}
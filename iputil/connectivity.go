@@ -0,0 +1,60 @@
+package iputil
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultConnectivityHosts 未配置时用于检测 IPv6 连通性的地址，未指定端口时使用 443
+var DefaultConnectivityHosts = []string{
+	"2400:3200:baba::1",    // 阿里云 IPv6
+	"2400:da00:2::29",      // 腾讯云 IPv6
+	"2606:4700:4700::1111", // Cloudflare IPv6
+}
+
+// CheckIPv6Connectivity 依次尝试通过 IPv6 连接 hosts，返回第一个连接成功的地址。
+// 全部失败时(如出站 443 被过滤)，若本机网卡上有全局 IPv6 地址也视为连通，返回该网卡与地址。
+func CheckIPv6Connectivity(hosts []string, timeout time.Duration) (string, error) {
+	if len(hosts) == 0 {
+		hosts = DefaultConnectivityHosts
+	}
+
+	var lastErr error
+	for _, host := range hosts {
+		addr := host
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			addr = net.JoinHostPort(host, "443")
+		}
+		conn, err := net.DialTimeout("tcp6", addr, timeout)
+		if err == nil {
+			conn.Close()
+			return addr, nil
+		}
+		lastErr = err
+	}
+
+	if source, ok := findGlobalIPv6(); ok {
+		return source, nil
+	}
+	return "", fmt.Errorf("no IPv6 host reachable and no global IPv6 address on any interface: %v", lastErr)
+}
+
+// findGlobalIPv6 查找网卡上的全局单播 IPv6 地址(不含链路本地与 ULA)
+func findGlobalIPv6() (string, bool) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", false
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		for _, ipStr := range findIPv6(iface) {
+			if ip := net.ParseIP(ipStr); ip.IsGlobalUnicast() && !ip.IsPrivate() {
+				return fmt.Sprintf("%s on %s", ipStr, iface.Name), true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,85 @@
+package iputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"ddns-ipv6/httpclient"
+)
+
+// DefaultIPv6EchoURLs 未配置时使用的公网 IPv6 回显服务
+var DefaultIPv6EchoURLs = []string{
+	"https://api6.ipify.org",
+	"https://v6.ident.me",
+}
+
+// httpDetectTimeout 单个回显服务的请求超时
+const httpDetectTimeout = 10 * time.Second
+
+// ipv6Client 只通过 IPv6 建立连接，确保回显服务看到的是本机的 IPv6 出口地址
+var ipv6Client = &http.Client{
+	Timeout: httpDetectTimeout,
+	Transport: httpclient.Wrap(&http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp6", addr)
+		},
+		TLSHandshakeTimeout: httpDetectTimeout,
+	}),
+}
+
+// GetPublicIPv6ViaHTTP 依次请求回显服务获取公网 IPv6，返回第一个有效结果
+func GetPublicIPv6ViaHTTP(ctx context.Context, urls []string) (string, error) {
+	if len(urls) == 0 {
+		urls = DefaultIPv6EchoURLs
+	}
+
+	var errs []error
+	for _, u := range urls {
+		ip, err := fetchIPv6(ctx, u)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			errs = append(errs, fmt.Errorf("%s: %v", u, err))
+			continue
+		}
+		return ip, nil
+	}
+	return "", fmt.Errorf("no valid IPv6 address from echo services: %w", errors.Join(errs...))
+}
+
+func fetchIPv6(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := ipv6Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", err
+	}
+	ip := strings.TrimSpace(string(body))
+	if !IsValidIPv6(ip) {
+		return "", fmt.Errorf("invalid IPv6 address in response: %q", ip)
+	}
+	if err := checkPublic(ip); err != nil {
+		return "", err
+	}
+	return ip, nil
+}
@@ -1,8 +1,18 @@
 package iputil
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"ddns-ipv6/config"
 )
 
 // IsValidIPv6 验证IPv6地址
@@ -11,14 +21,269 @@ func IsValidIPv6(ip string) bool {
 	return parsedIP != nil && parsedIP.To4() == nil && parsedIP.To16() != nil
 }
 
-// GetLocalIPv6 获取本地IPv6地址
-func GetLocalIPv6() (string, error) {
+// 内核地址标志位，取值见 linux/if_addr.h
+const (
+	ifaFlagTemporary  = 0x01
+	ifaFlagDeprecated = 0x20
+)
+
+// GetLocalIPv6 按 cfg.DetectionChain() 的顺序依次尝试各检测方式，返回第一个可发布的地址及得到该地址的检测方式，
+// ctx 用于限制 http 与 upnp 检测的耗时。全部失败时返回汇总各方式错误的错误
+func GetLocalIPv6(ctx context.Context, cfg config.Network) (string, string, error) {
+	chain := cfg.DetectionChain()
+	var errs []error
+	for _, method := range chain {
+		ip, err := detectIPv6(ctx, method, cfg)
+		if err == nil {
+			if err = CheckPublishable(ip, cfg.AddressScope); err != nil {
+				err = fmt.Errorf("refusing to publish detected address: %w", err)
+			}
+		}
+		if err == nil {
+			if len(errs) > 0 {
+				logrus.Infof("IPv6 address %s detected via %s after %d failed detection methods", ip, method, len(errs))
+			} else {
+				logrus.Debugf("IPv6 address %s detected via %s", ip, method)
+			}
+			return ip, method, nil
+		}
+		if ctx.Err() != nil {
+			return "", method, ctx.Err()
+		}
+		if len(chain) == 1 {
+			return "", method, err
+		}
+		logrus.Debugf("IPv6 detection via %s failed: %v", method, err)
+		errs = append(errs, fmt.Errorf("%s: %w", method, err))
+	}
+	return "", strings.Join(chain, ","), fmt.Errorf("all IPv6 detection methods failed: %w", errors.Join(errs...))
+}
+
+// detectIPv6 使用单个检测方式获取 IPv6 地址
+func detectIPv6(ctx context.Context, method string, cfg config.Network) (string, error) {
+	switch method {
+	case "interface":
+		return getIPv6ViaInterface(cfg)
+	case "http":
+		return GetPublicIPv6ViaHTTP(ctx, cfg.DetectionURLs)
+	case "upnp":
+		return GetPublicIPv6ViaGateway(ctx, cfg)
+	default:
+		return "", fmt.Errorf("unknown detection method %q", method)
+	}
+}
+
+// getIPv6ViaInterface 从本机网卡获取 IPv6 地址，配置了网卡名时只检测该网卡
+func getIPv6ViaInterface(cfg config.Network) (string, error) {
+	if cfg.Interface != "" {
+		return getIPv6ForInterface(cfg.Interface, cfg)
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []string
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || skipTunnelInterface(iface, cfg) {
+			continue
+		}
+		candidates = append(candidates, findIPv6(iface)...)
+	}
+
+	candidates, err = filterCandidates(candidates, cfg)
+	if err != nil {
+		return "", err
+	}
+	if ip, ok := selectIPv6(candidates, cfg.PreferStableIPv6); ok {
+		return ip, nil
+	}
+	return "", fmt.Errorf("no valid IPv6 address found")
+}
+
+// GetLocalIPv6Addresses 返回所有符合条件的本地 IPv6 地址(已排序、去重)，用于在同一记录上发布多个地址。
+// 筛选规则与 GetLocalIPv6 相同，preferStable 时排除临时与已废弃地址(只有这类地址时保留)
+func GetLocalIPv6Addresses(cfg config.Network) ([]string, error) {
+	var interfaces []net.Interface
+	if cfg.Interface != "" {
+		iface, err := net.InterfaceByName(cfg.Interface)
+		if err != nil {
+			return nil, fmt.Errorf("interface %s not found: %v", cfg.Interface, err)
+		}
+		if iface.Flags&net.FlagUp == 0 {
+			return nil, fmt.Errorf("interface %s is down", cfg.Interface)
+		}
+		interfaces = []net.Interface{*iface}
+	} else {
+		all, err := net.Interfaces()
+		if err != nil {
+			return nil, err
+		}
+		for _, iface := range all {
+			if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagLoopback == 0 && !skipTunnelInterface(iface, cfg) {
+				interfaces = append(interfaces, iface)
+			}
+		}
+	}
+
+	var candidates []string
+	for _, iface := range interfaces {
+		candidates = append(candidates, findIPv6(iface)...)
+	}
+	candidates, err := filterCandidates(candidates, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no valid IPv6 address found")
+	}
+
+	if cfg.PreferStableIPv6 {
+		if flags, err := readAddrFlags(); err != nil {
+			logrus.Warnf("Unable to read IPv6 address flags, stable address preference ignored: %v", err)
+		} else {
+			var stable []string
+			for _, ip := range candidates {
+				if flags[ip]&(ifaFlagTemporary|ifaFlagDeprecated) == 0 {
+					stable = append(stable, ip)
+				}
+			}
+			if len(stable) > 0 {
+				candidates = stable
+			}
+		}
+	}
+	return SortAddresses(candidates), nil
+}
+
+// SortAddresses 返回按地址大小排序并去重的地址列表，用于比较地址集合
+func SortAddresses(ips []string) []string {
+	addrs := make([]netip.Addr, 0, len(ips))
+	for _, ip := range ips {
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+	slices.SortFunc(addrs, netip.Addr.Compare)
+	addrs = slices.Compact(addrs)
+
+	result := make([]string, len(addrs))
+	for i, addr := range addrs {
+		result[i] = addr.String()
+	}
+	return result
+}
+
+// GetLocalIPv6ForInterface 获取指定网卡上的IPv6地址
+func GetLocalIPv6ForInterface(name string, preferStable bool) (string, error) {
+	return getIPv6ForInterface(name, config.Network{PreferStableIPv6: preferStable})
+}
+
+func getIPv6ForInterface(name string, cfg config.Network) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", fmt.Errorf("interface %s not found: %v", name, err)
+	}
+	if iface.Flags&net.FlagUp == 0 {
+		return "", fmt.Errorf("interface %s is down", name)
+	}
+
+	candidates, err := filterCandidates(findIPv6(*iface), cfg)
+	if err != nil {
+		return "", err
+	}
+	if ip, ok := selectIPv6(candidates, cfg.PreferStableIPv6); ok {
+		return ip, nil
+	}
+	return "", fmt.Errorf("no valid IPv6 address found on interface %s", name)
+}
+
+// filterCandidates 依次按保留网段、隧道地址、地址范围、剩余生存期与后缀筛选候选地址
+func filterCandidates(candidates []string, cfg config.Network) ([]string, error) {
+	candidates, err := filterReserved(candidates)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.AllowTunnelAddresses {
+		if candidates, err = filterTunnel(candidates); err != nil {
+			return nil, err
+		}
+	}
+	if candidates, err = filterScope(candidates, cfg.AddressScope); err != nil {
+		return nil, err
+	}
+	candidates = filterLifetime(candidates, time.Duration(cfg.MinPreferredLifetime)*time.Second)
+	return filterSuffix(candidates, cfg)
+}
+
+// skipTunnelInterface 遍历网卡时跳过隧道伪网卡，除非开启了 AllowTunnelAddresses
+func skipTunnelInterface(iface net.Interface, cfg config.Network) bool {
+	if cfg.AllowTunnelAddresses || !isTunnelInterface(iface.Name) {
+		return false
+	}
+	logrus.Debugf("Skipping tunnel interface %s", iface.Name)
+	return true
+}
+
+// findIPv6 返回网卡上的所有IPv6地址
+func findIPv6(iface net.Interface) []string {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil
+	}
+
+	var result []string
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok {
+			if ip := ipnet.IP.To16(); ip != nil && ip.To4() == nil {
+				ipStr := ip.String()
+				if IsValidIPv6(ipStr) {
+					result = append(result, ipStr)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// selectIPv6 从候选地址中选出一个，preferStable 时跳过临时(隐私扩展)和已废弃地址
+func selectIPv6(candidates []string, preferStable bool) (string, bool) {
+	if len(candidates) == 0 {
+		return "", false
+	}
+	if !preferStable {
+		return candidates[0], true
+	}
+
+	flags, err := readAddrFlags()
+	if err != nil {
+		logrus.Warnf("Unable to read IPv6 address flags, stable address preference ignored: %v", err)
+		return candidates[0], true
+	}
+
+	for _, ip := range candidates {
+		if flags[ip]&(ifaFlagTemporary|ifaFlagDeprecated) == 0 {
+			return ip, true
+		}
+	}
+
+	logrus.Warnf("Only temporary or deprecated IPv6 addresses available, using %s; the published address may change frequently", candidates[0])
+	return candidates[0], true
+}
+
+// GetLocalIPv4 获取本地公网IPv4地址，配置了网卡名时只检测该网卡。
+// 读取网卡不会阻塞，ctx 仅为与 GetLocalIPv6 保持一致的签名。
+func GetLocalIPv4(_ context.Context, cfg config.Network) (string, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
 		return "", err
 	}
 
 	for _, iface := range interfaces {
+		if cfg.Interface != "" && iface.Name != cfg.Interface {
+			continue
+		}
 		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
 			continue
 		}
@@ -29,15 +294,36 @@ func GetLocalIPv6() (string, error) {
 		}
 
 		for _, addr := range addrs {
-			if ipnet, ok := addr.(*net.IPNet); ok {
-				if ip := ipnet.IP.To16(); ip != nil && ip.To4() == nil {
-					ipStr := ip.String()
-					if IsValidIPv6(ipStr) {
-						return ipStr, nil
-					}
-				}
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip := ipnet.IP.To4()
+			if ip == nil {
+				continue
 			}
+			if name := reservedRange(ip.String()); name != "" {
+				logrus.Debugf("Skipping non-public IPv4 address %s (%s)", ip, name)
+				continue
+			}
+			return ip.String(), nil
 		}
 	}
-	return "", fmt.Errorf("no valid IPv6 address found")
+	if cfg.Interface != "" {
+		return "", fmt.Errorf("no public IPv4 address found on interface %s", cfg.Interface)
+	}
+	return "", fmt.Errorf("no public IPv4 address found")
+}
+
+// MaskIPv6 返回 ip 保留前 prefixLen 位后的网络前缀，如 2001:db8:1:2::/64
+func MaskIPv6(ip string, prefixLen int) (string, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() != nil {
+		return "", fmt.Errorf("invalid IPv6 address %q", ip)
+	}
+	if prefixLen < 0 || prefixLen > 128 {
+		return "", fmt.Errorf("invalid IPv6 prefix length %d", prefixLen)
+	}
+	network := parsed.Mask(net.CIDRMask(prefixLen, 128))
+	return fmt.Sprintf("%s/%d", network, prefixLen), nil
 }
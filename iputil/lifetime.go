@@ -0,0 +1,41 @@
+package iputil
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// lifetimeUnavailable 无法读取地址生存期的提示只记录一次
+var lifetimeUnavailable sync.Once
+
+// filterLifetime 去掉剩余首选生存期不足 minLifetime 的地址，优先使用较新的地址；
+// 所有地址都即将过期时保留原候选，无法读取生存期时跳过筛选
+func filterLifetime(candidates []string, minLifetime time.Duration) []string {
+	if minLifetime <= 0 || len(candidates) == 0 {
+		return candidates
+	}
+	lifetimes, err := readPreferredLifetimes()
+	if err != nil {
+		lifetimeUnavailable.Do(func() {
+			logrus.Warnf("Unable to read IPv6 address lifetimes, network.minPreferredLifetime filter skipped: %v", err)
+		})
+		return candidates
+	}
+
+	var result []string
+	for _, ip := range candidates {
+		lifetime, ok := lifetimes[ip]
+		if !ok || lifetime < 0 || lifetime >= minLifetime {
+			result = append(result, ip)
+		} else {
+			logrus.Debugf("Skipping %s: preferred lifetime %s is below %s", ip, lifetime, minLifetime)
+		}
+	}
+	if len(result) == 0 {
+		logrus.Warnf("All IPv6 addresses have less than %s preferred lifetime left, using them anyway", minLifetime)
+		return candidates
+	}
+	return result
+}
@@ -0,0 +1,59 @@
+package iputil
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ifaCacheInfo IFA_CACHEINFO 属性类型，取值见 linux/if_addr.h
+const ifaCacheInfo = 6
+
+// readPreferredLifetimes 通过 netlink 读取各 IPv6 地址剩余的首选生存期，永久地址为 -1
+func readPreferredLifetimes() (map[string]time.Duration, error) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_INET6)
+	if err != nil {
+		return nil, fmt.Errorf("netlink RTM_GETADDR: %v", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, fmt.Errorf("parse netlink messages: %v", err)
+	}
+
+	lifetimes := make(map[string]time.Duration)
+	for _, msg := range msgs {
+		if msg.Header.Type != syscall.RTM_NEWADDR {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&msg)
+		if err != nil {
+			continue
+		}
+		var ip net.IP
+		lifetime, ok := time.Duration(0), false
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case syscall.IFA_ADDRESS:
+				if len(attr.Value) == net.IPv6len {
+					ip = net.IP(attr.Value)
+				}
+			case ifaCacheInfo:
+				// struct ifa_cacheinfo { __u32 ifa_prefered; __u32 ifa_valid; __u32 cstamp; __u32 tstamp; }
+				if len(attr.Value) >= 4 {
+					preferred := binary.NativeEndian.Uint32(attr.Value[:4])
+					lifetime, ok = time.Duration(preferred)*time.Second, true
+					if preferred == math.MaxUint32 {
+						lifetime = -1
+					}
+				}
+			}
+		}
+		if ip != nil && ok {
+			lifetimes[ip.String()] = lifetime
+		}
+	}
+	return lifetimes, nil
+}
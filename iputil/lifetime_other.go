@@ -0,0 +1,13 @@
+//go:build !linux
+
+package iputil
+
+import (
+	"errors"
+	"time"
+)
+
+// readPreferredLifetimes 当前平台无法读取地址生存期
+func readPreferredLifetimes() (map[string]time.Duration, error) {
+	return nil, errors.New("address lifetimes are not available on this platform")
+}
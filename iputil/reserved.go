@@ -0,0 +1,121 @@
+package iputil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNoPublicIPv6 候选地址都在保留网段内，没有可发布到 DNS 的 IPv6 地址
+var ErrNoPublicIPv6 = errors.New("no usable public IPv6 address")
+
+// reservedIPv6 不可在公网路由的保留网段，取自 IANA IPv6 Special-Purpose Address Registry。
+// ULA(fc00::/7) 由 addressScope 控制，不在此列。
+var reservedIPv6 = []struct {
+	prefix netip.Prefix
+	name   string
+}{
+	{netip.MustParsePrefix("::/128"), "unspecified"},
+	{netip.MustParsePrefix("::1/128"), "loopback"},
+	{netip.MustParsePrefix("::ffff:0:0/96"), "IPv4-mapped"},
+	{netip.MustParsePrefix("64:ff9b:1::/48"), "local-use NAT64"},
+	{netip.MustParsePrefix("100::/64"), "discard-only"},
+	{netip.MustParsePrefix("2001:2::/48"), "benchmarking"},
+	{netip.MustParsePrefix("2001:db8::/32"), "documentation"},
+	{netip.MustParsePrefix("3fff::/20"), "documentation"},
+	{netip.MustParsePrefix("fe80::/10"), "link-local"},
+	{netip.MustParsePrefix("ff00::/8"), "multicast"},
+}
+
+// reservedIPv4 不可作为公网 A 记录发布的 IPv4 网段，取自 IANA IPv4 Special-Purpose Address Registry，
+// 包括运营商级 NAT(CGNAT，100.64.0.0/10)分配的共享地址
+var reservedIPv4 = []struct {
+	prefix netip.Prefix
+	name   string
+}{
+	{netip.MustParsePrefix("0.0.0.0/8"), "this-network"},
+	{netip.MustParsePrefix("10.0.0.0/8"), "private"},
+	{netip.MustParsePrefix("100.64.0.0/10"), "CGNAT shared"},
+	{netip.MustParsePrefix("127.0.0.0/8"), "loopback"},
+	{netip.MustParsePrefix("169.254.0.0/16"), "link-local"},
+	{netip.MustParsePrefix("172.16.0.0/12"), "private"},
+	{netip.MustParsePrefix("192.0.0.0/24"), "IETF protocol assignment"},
+	{netip.MustParsePrefix("192.0.2.0/24"), "documentation"},
+	{netip.MustParsePrefix("192.168.0.0/16"), "private"},
+	{netip.MustParsePrefix("198.18.0.0/15"), "benchmarking"},
+	{netip.MustParsePrefix("198.51.100.0/24"), "documentation"},
+	{netip.MustParsePrefix("203.0.113.0/24"), "documentation"},
+	{netip.MustParsePrefix("224.0.0.0/4"), "multicast"},
+	{netip.MustParsePrefix("240.0.0.0/4"), "reserved"},
+}
+
+// reservedRange 返回地址所在保留网段的名称，IPv4 地址按 reservedIPv4 判断；不在保留网段内时返回空字符串
+func reservedRange(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "invalid"
+	}
+	addr = addr.WithZone("")
+	ranges := reservedIPv6
+	if addr.Is4() {
+		ranges = reservedIPv4
+	}
+	for _, r := range ranges {
+		if r.prefix.Contains(addr) {
+			return r.name
+		}
+	}
+	return ""
+}
+
+// checkPublic 校验单个地址不在保留网段内，否则返回包装 ErrNoPublicIPv6 的错误
+func checkPublic(ip string) error {
+	if name := reservedRange(ip); name != "" {
+		return fmt.Errorf("%w: %s is a %s address", ErrNoPublicIPv6, ip, name)
+	}
+	return nil
+}
+
+// IsPublishableIPv6 判断地址能否作为 AAAA 记录发布到公网：必须是全局单播 IPv6 地址，
+// 排除链路本地、ULA、回环及其他保留网段
+func IsPublishableIPv6(addr string) bool {
+	return CheckPublishable(addr, "global") == nil
+}
+
+// CheckPublishable 在发布前最后校验地址：必须是不在保留网段内的 IPv6 地址且属于 addressScope，
+// 否则返回包装 ErrNoPublicIPv6 的错误
+func CheckPublishable(ip, scope string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !addr.Is6() || addr.Is4In6() {
+		return fmt.Errorf("%w: %q is not an IPv6 address", ErrNoPublicIPv6, ip)
+	}
+	if err := checkPublic(ip); err != nil {
+		return err
+	}
+	if !inScope(net.IP(addr.WithZone("").AsSlice()), scope) {
+		if scope == "" {
+			scope = "global"
+		}
+		return fmt.Errorf("%w: %s is outside of address scope %s", ErrNoPublicIPv6, ip, scope)
+	}
+	return nil
+}
+
+// filterReserved 去掉保留网段内的候选地址，候选地址全部被去掉时返回包装 ErrNoPublicIPv6 的错误
+func filterReserved(candidates []string) ([]string, error) {
+	var result []string
+	for _, candidate := range candidates {
+		if name := reservedRange(candidate); name != "" {
+			logrus.Debugf("Skipping non-routable IPv6 address %s (%s)", candidate, name)
+			continue
+		}
+		result = append(result, candidate)
+	}
+	if len(result) == 0 && len(candidates) > 0 {
+		return nil, fmt.Errorf("%w: only non-routable addresses found: %v", ErrNoPublicIPv6, candidates)
+	}
+	return result, nil
+}
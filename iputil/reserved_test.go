@@ -0,0 +1,230 @@
+package iputil
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// reservedCases 每个保留网段的首尾地址及紧邻网段之外的地址，want 为空表示不在保留网段内
+var reservedCases = []struct {
+	ip   string
+	want string
+}{
+	{"::", "unspecified"},
+	{"::2", ""},
+
+	{"::1", "loopback"},
+	{"::1:0", ""},
+
+	{"::ffff:0:0", "IPv4-mapped"},
+	{"::ffff:ffff:ffff", "IPv4-mapped"},
+	{"::fffe:ffff:ffff", ""},
+	{"::1:0:0:0", ""},
+
+	{"64:ff9b:1::", "local-use NAT64"},
+	{"64:ff9b:1:ffff:ffff:ffff:ffff:ffff", "local-use NAT64"},
+	{"64:ff9b:0:ffff:ffff:ffff:ffff:ffff", ""},
+	{"64:ff9b:2::", ""},
+
+	{"100::", "discard-only"},
+	{"100::ffff:ffff:ffff:ffff", "discard-only"},
+	{"ff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", ""},
+	{"100:0:0:1::", ""},
+
+	{"2001:2::", "benchmarking"},
+	{"2001:2:0:ffff:ffff:ffff:ffff:ffff", "benchmarking"},
+	{"2001:1:ffff:ffff:ffff:ffff:ffff:ffff", ""},
+	{"2001:2:1::", ""},
+
+	{"2001:db8::", "documentation"},
+	{"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", "documentation"},
+	{"2001:db7:ffff:ffff:ffff:ffff:ffff:ffff", ""},
+	{"2001:db9::", ""},
+
+	{"3fff::", "documentation"},
+	{"3fff:fff:ffff:ffff:ffff:ffff:ffff:ffff", "documentation"},
+	{"3ffe:ffff:ffff:ffff:ffff:ffff:ffff:ffff", ""},
+	{"3fff:1000::", ""},
+
+	{"fe80::", "link-local"},
+	{"febf:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "link-local"},
+	{"fe7f:ffff:ffff:ffff:ffff:ffff:ffff:ffff", ""},
+	{"fec0::", ""},
+
+	{"ff00::", "multicast"},
+	{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "multicast"},
+	{"feff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", ""},
+
+	{"fe80::1%eth0", "link-local"},
+	{"2400:3200::1", ""},
+	{"not-an-ip", "invalid"},
+
+	{"0.0.0.0", "this-network"},
+	{"0.255.255.255", "this-network"},
+	{"1.0.0.0", ""},
+
+	{"10.0.0.0", "private"},
+	{"10.255.255.255", "private"},
+	{"9.255.255.255", ""},
+	{"11.0.0.0", ""},
+
+	{"100.64.0.0", "CGNAT shared"},
+	{"100.127.255.255", "CGNAT shared"},
+	{"100.63.255.255", ""},
+	{"100.128.0.0", ""},
+
+	{"127.0.0.0", "loopback"},
+	{"127.255.255.255", "loopback"},
+	{"126.255.255.255", ""},
+	{"128.0.0.0", ""},
+
+	{"169.254.0.0", "link-local"},
+	{"169.254.255.255", "link-local"},
+	{"169.253.255.255", ""},
+	{"169.255.0.0", ""},
+
+	{"172.16.0.0", "private"},
+	{"172.31.255.255", "private"},
+	{"172.15.255.255", ""},
+	{"172.32.0.0", ""},
+
+	{"192.0.0.0", "IETF protocol assignment"},
+	{"192.0.0.255", "IETF protocol assignment"},
+	{"191.255.255.255", ""},
+	{"192.0.1.0", ""},
+
+	{"192.0.2.0", "documentation"},
+	{"192.0.2.255", "documentation"},
+	{"192.0.3.0", ""},
+
+	{"192.168.0.0", "private"},
+	{"192.168.255.255", "private"},
+	{"192.167.255.255", ""},
+	{"192.169.0.0", ""},
+
+	{"198.18.0.0", "benchmarking"},
+	{"198.19.255.255", "benchmarking"},
+	{"198.17.255.255", ""},
+	{"198.20.0.0", ""},
+
+	{"198.51.100.0", "documentation"},
+	{"198.51.100.255", "documentation"},
+	{"198.51.99.255", ""},
+	{"198.51.101.0", ""},
+
+	{"203.0.113.0", "documentation"},
+	{"203.0.113.255", "documentation"},
+	{"203.0.112.255", ""},
+	{"203.0.114.0", ""},
+
+	{"224.0.0.0", "multicast"},
+	{"239.255.255.255", "multicast"},
+	{"223.255.255.255", ""},
+
+	{"240.0.0.0", "reserved"},
+	{"255.255.255.255", "reserved"},
+}
+
+func TestReservedRange(t *testing.T) {
+	for _, tt := range reservedCases {
+		if got := reservedRange(tt.ip); got != tt.want {
+			t.Errorf("reservedRange(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+// TestReservedRangesCovered 确保新增的保留网段也在 reservedCases 中有首尾地址的用例
+func TestReservedRangesCovered(t *testing.T) {
+	var covered []netip.Addr
+	for _, tt := range reservedCases {
+		if addr, err := netip.ParseAddr(tt.ip); err == nil && tt.want != "" {
+			covered = append(covered, addr.WithZone(""))
+		}
+	}
+	for _, ranges := range [][]struct {
+		prefix netip.Prefix
+		name   string
+	}{reservedIPv6, reservedIPv4} {
+		for _, r := range ranges {
+			first := r.prefix.Masked().Addr()
+			last := lastAddr(r.prefix)
+			if !slices.Contains(covered, first) || !slices.Contains(covered, last) {
+				t.Errorf("reserved range %s (%s) lacks test cases for %s and %s", r.prefix, r.name, first, last)
+			}
+		}
+	}
+}
+
+// lastAddr 返回网段内的最后一个地址
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Masked().Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+func TestFilterReserved(t *testing.T) {
+	got, err := filterReserved([]string{"fe80::1", "2001:db8::1", "2400:3200::1", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2400:3200::1"}; !slices.Equal(got, want) {
+		t.Errorf("filterReserved = %v, want %v", got, want)
+	}
+
+	_, err = filterReserved([]string{"2001:db8::1", "3fff::1", "64:ff9b:1::1"})
+	if !errors.Is(err, ErrNoPublicIPv6) {
+		t.Errorf("filterReserved with only reserved addresses: err = %v, want ErrNoPublicIPv6", err)
+	}
+}
+
+func TestCheckPublishable(t *testing.T) {
+	tests := []struct {
+		ip string
+		// reason 为 CheckPublishable 错误信息应包含的原因，为空表示可以发布
+		reason string
+	}{
+		{"fe80::1", "link-local"},
+		{"fe80::1%eth0", "link-local"},
+		{"fd12:3456::1", "outside of address scope global"},
+		{"fc00::1", "outside of address scope global"},
+		{"::1", "loopback"},
+		{"::", "unspecified"},
+		{"ff02::1", "multicast"},
+		{"ff0e::1", "multicast"},
+		{"2001:db8::1", "documentation"},
+		{"192.0.2.1", "is not an IPv6 address"},
+		{"::ffff:192.0.2.1", "is not an IPv6 address"},
+		{"not-an-ip", "is not an IPv6 address"},
+		{"2400:3200::1", ""},
+		{"2408:8000:1:2::3", ""},
+	}
+	for _, tt := range tests {
+		err := CheckPublishable(tt.ip, "global")
+		if got := IsPublishableIPv6(tt.ip); got != (tt.reason == "") {
+			t.Errorf("IsPublishableIPv6(%q) = %v, want %v", tt.ip, got, tt.reason == "")
+		}
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("CheckPublishable(%q) = %v, want nil", tt.ip, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrNoPublicIPv6) || !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("CheckPublishable(%q) = %v, want ErrNoPublicIPv6 mentioning %q", tt.ip, err, tt.reason)
+		}
+	}
+
+	// 放宽地址范围后 ULA 可以发布，链路本地地址仍被拒绝
+	if err := CheckPublishable("fd12:3456::1", "ula"); err != nil {
+		t.Errorf("CheckPublishable(ULA, ula) = %v, want nil", err)
+	}
+	if err := CheckPublishable("fe80::1", "any"); !errors.Is(err, ErrNoPublicIPv6) {
+		t.Errorf("CheckPublishable(link-local, any) = %v, want ErrNoPublicIPv6", err)
+	}
+}
@@ -0,0 +1,47 @@
+package iputil
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ipv6ULA 唯一本地地址(ULA)网段
+var ipv6ULA = &net.IPNet{IP: net.ParseIP("fc00::"), Mask: net.CIDRMask(7, 128)}
+
+// inScope 判断地址是否属于指定范围: global(默认，公网可路由)、ula(唯一本地地址) 或 any(两者皆可)。
+// 链路本地、回环等地址在任何范围下都不可用于解析记录。
+func inScope(ip net.IP, scope string) bool {
+	if !ip.IsGlobalUnicast() {
+		return false
+	}
+	ula := ipv6ULA.Contains(ip)
+	switch scope {
+	case "ula":
+		return ula
+	case "any":
+		return true
+	default:
+		return !ula
+	}
+}
+
+// filterScope 去掉不在指定范围内的候选地址，候选地址全部被去掉时返回包装 ErrNoPublicIPv6 的错误
+func filterScope(candidates []string, scope string) ([]string, error) {
+	var result []string
+	for _, candidate := range candidates {
+		if ip := net.ParseIP(candidate); ip != nil && inScope(ip, scope) {
+			result = append(result, candidate)
+		} else {
+			logrus.Debugf("Skipping IPv6 address %s outside of address scope %q", candidate, scope)
+		}
+	}
+	if len(result) == 0 && len(candidates) > 0 {
+		if scope == "" {
+			scope = "global"
+		}
+		return nil, fmt.Errorf("%w: no address in scope %s found: %v", ErrNoPublicIPv6, scope, candidates)
+	}
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package iputil
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"ddns-ipv6/config"
+)
+
+// scopeCandidates 同时包含链路本地、ULA、回环、组播与全局单播地址的候选列表
+var scopeCandidates = []string{"fe80::1", "fd12:3456::1", "::1", "ff02::1", "2400:3200::1", "fc00::2", "2408:8000::2"}
+
+func TestFilterScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  []string
+	}{
+		{"", []string{"2400:3200::1", "2408:8000::2"}},
+		{"global", []string{"2400:3200::1", "2408:8000::2"}},
+		{"ula", []string{"fd12:3456::1", "fc00::2"}},
+		{"any", []string{"fd12:3456::1", "2400:3200::1", "fc00::2", "2408:8000::2"}},
+	}
+	for _, tt := range tests {
+		got, err := filterScope(scopeCandidates, tt.scope)
+		if err != nil {
+			t.Errorf("filterScope(%q) error: %v", tt.scope, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("filterScope(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestFilterScopeNoCandidateLeft(t *testing.T) {
+	tests := []struct {
+		scope      string
+		candidates []string
+	}{
+		{"global", []string{"fe80::1", "fd12:3456::1"}},
+		{"ula", []string{"fe80::1", "2400:3200::1"}},
+		{"any", []string{"fe80::1", "::1", "ff02::1"}},
+	}
+	for _, tt := range tests {
+		got, err := filterScope(tt.candidates, tt.scope)
+		if !errors.Is(err, ErrNoPublicIPv6) {
+			t.Errorf("filterScope(%v, %q) = %v, %v, want ErrNoPublicIPv6", tt.candidates, tt.scope, got, err)
+		}
+	}
+
+	// 没有候选地址时不是范围筛选的错误
+	if got, err := filterScope(nil, "global"); err != nil || len(got) != 0 {
+		t.Errorf("filterScope(nil) = %v, %v, want empty result without error", got, err)
+	}
+}
+
+func TestFilterCandidatesExcludesLinkLocalAndULA(t *testing.T) {
+	got, err := filterCandidates(scopeCandidates, config.Network{AddressScope: "global"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2400:3200::1", "2408:8000::2"}; !slices.Equal(got, want) {
+		t.Errorf("filterCandidates = %v, want %v", got, want)
+	}
+	if ip, ok := selectIPv6(got, false); !ok || ip != "2400:3200::1" {
+		t.Errorf("selectIPv6 = %q, %v, want 2400:3200::1", ip, ok)
+	}
+
+	_, err = filterCandidates([]string{"fe80::1", "fd12:3456::1"}, config.Network{})
+	if !errors.Is(err, ErrNoPublicIPv6) {
+		t.Errorf("filterCandidates with only link-local and ULA addresses: err = %v, want ErrNoPublicIPv6", err)
+	}
+}
@@ -0,0 +1,51 @@
+package iputil
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/sirupsen/logrus"
+
+	"ddns-ipv6/config"
+)
+
+// hasSuffix 判断 ip 的末尾是否与后缀一致，按 16 位分组比较
+func hasSuffix(ip string, suffix []byte) bool {
+	parsed := net.ParseIP(ip).To16()
+	if parsed == nil {
+		return false
+	}
+	tail := parsed[len(parsed)-len(suffix):]
+	for i := range suffix {
+		if tail[i] != suffix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// filterSuffix 按 IPv6Suffix 筛选候选地址。RequireIPv6Suffix 时没有匹配地址即报错，否则回退到全部候选地址
+func filterSuffix(candidates []string, cfg config.Network) ([]string, error) {
+	if cfg.IPv6Suffix == "" {
+		return candidates, nil
+	}
+	suffix, err := cfg.ParseIPv6Suffix()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, ip := range candidates {
+		if hasSuffix(ip, suffix) {
+			matched = append(matched, ip)
+		}
+	}
+	if len(matched) > 0 {
+		return matched, nil
+	}
+	if cfg.RequireIPv6Suffix {
+		return nil, fmt.Errorf("no IPv6 address with suffix %s found among %v", cfg.IPv6Suffix, candidates)
+	}
+	logrus.Warnf("No IPv6 address with suffix %s found, falling back to other addresses", cfg.IPv6Suffix)
+	return candidates, nil
+}
@@ -0,0 +1,74 @@
+package iputil
+
+import (
+	"fmt"
+	"net/netip"
+	"runtime"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// tunnelIPv6 IPv4 过渡隧道使用的网段，地址看似全局单播，但只是 IPv4 地址的映射，不能代表本机的 IPv6 接入
+var tunnelIPv6 = []struct {
+	prefix netip.Prefix
+	name   string
+}{
+	{netip.MustParsePrefix("2001::/32"), "Teredo"},
+	{netip.MustParsePrefix("2002::/16"), "6to4"},
+}
+
+// tunnelInterfacePrefixes 各系统上隧道伪网卡名称的前缀(不区分大小写)：
+// Windows 的 Teredo/ISATAP/6to4 伪接口、macOS 的 stf(6to4)与 gif，以及 Linux 的 sit 与 miredo 创建的 teredo
+var tunnelInterfacePrefixes = map[string][]string{
+	"windows": {"teredo", "isatap", "6to4"},
+	"darwin":  {"stf", "gif"},
+	"linux":   {"sit", "teredo"},
+}
+
+// tunnelRange 返回地址所属的隧道类型，不是隧道地址时返回空字符串。
+// ISATAP 地址的接口标识为 0000:5efe 或 0200:5efe 加 IPv4 地址(RFC 5214)，可出现在任意前缀下
+func tunnelRange(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.WithZone("")
+	for _, r := range tunnelIPv6 {
+		if r.prefix.Contains(addr) {
+			return r.name
+		}
+	}
+	b := addr.As16()
+	if (b[8]|0x02) == 0x02 && b[9] == 0 && b[10] == 0x5e && b[11] == 0xfe {
+		return "ISATAP"
+	}
+	return ""
+}
+
+// isTunnelInterface 判断网卡是否为当前系统上已知的隧道伪网卡
+func isTunnelInterface(name string) bool {
+	name = strings.ToLower(name)
+	for _, prefix := range tunnelInterfacePrefixes[runtime.GOOS] {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTunnel 去掉 Teredo、6to4 与 ISATAP 隧道地址，候选地址全部被去掉时返回包装 ErrNoPublicIPv6 的错误
+func filterTunnel(candidates []string) ([]string, error) {
+	var result []string
+	for _, candidate := range candidates {
+		if name := tunnelRange(candidate); name != "" {
+			logrus.Debugf("Skipping %s tunnel address %s, set network.allowTunnelAddresses to use it", name, candidate)
+			continue
+		}
+		result = append(result, candidate)
+	}
+	if len(result) == 0 && len(candidates) > 0 {
+		return nil, fmt.Errorf("%w: only tunnel (Teredo, 6to4 or ISATAP) addresses found: %v", ErrNoPublicIPv6, candidates)
+	}
+	return result, nil
+}
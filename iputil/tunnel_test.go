@@ -0,0 +1,116 @@
+package iputil
+
+import (
+	"errors"
+	"net"
+	"runtime"
+	"slices"
+	"testing"
+
+	"ddns-ipv6/config"
+)
+
+func TestTunnelRange(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"2001::1", "Teredo"},
+		{"2001:0:4136:e378:8000:63bf:3fff:fdd2", "Teredo"},
+		{"2001:0:ffff:ffff:ffff:ffff:ffff:ffff", "Teredo"},
+		{"2001:1::1", ""},
+		{"2002::1", "6to4"},
+		{"2002:c000:204::1", "6to4"},
+		{"2002:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "6to4"},
+		{"2003::1", ""},
+		{"2400:3200::5efe:c000:201", "ISATAP"},
+		{"2400:3200::200:5efe:c000:201", "ISATAP"},
+		{"fe80::5efe:a00:1%isatap0", "ISATAP"},
+		{"2400:3200::100:5efe:c000:201", ""},
+		{"2400:3200::5eff:c000:201", ""},
+		{"2400:3200::1", ""},
+		{"not-an-ip", ""},
+	}
+	for _, tt := range tests {
+		if got := tunnelRange(tt.ip); got != tt.want {
+			t.Errorf("tunnelRange(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+// tunnelInterfaces 各系统上应识别为隧道伪网卡的名称
+var tunnelInterfaces = map[string][]string{
+	"windows": {"Teredo Tunneling Pseudo-Interface", "isatap.{1234}", "6TO4 Adapter"},
+	"darwin":  {"stf0", "gif0"},
+	"linux":   {"sit0", "SIT1", "teredo"},
+}
+
+func TestIsTunnelInterface(t *testing.T) {
+	names, ok := tunnelInterfaces[runtime.GOOS]
+	if !ok {
+		t.Skipf("no tunnel interface names known on %s", runtime.GOOS)
+	}
+	for _, name := range names {
+		if !isTunnelInterface(name) {
+			t.Errorf("isTunnelInterface(%q) = false, want true", name)
+		}
+	}
+	for _, name := range []string{"eth0", "en0", "wlan0", "Ethernet", "pppoe-wan"} {
+		if isTunnelInterface(name) {
+			t.Errorf("isTunnelInterface(%q) = true, want false", name)
+		}
+	}
+
+	// 隧道网卡默认跳过，开启 AllowTunnelAddresses 后照常使用
+	iface := net.Interface{Name: names[0]}
+	if !skipTunnelInterface(iface, config.Network{}) {
+		t.Errorf("skipTunnelInterface(%q) = false, want true by default", iface.Name)
+	}
+	if skipTunnelInterface(iface, config.Network{AllowTunnelAddresses: true}) {
+		t.Errorf("skipTunnelInterface(%q) = true with allowTunnelAddresses", iface.Name)
+	}
+	if skipTunnelInterface(net.Interface{Name: "eth0"}, config.Network{}) {
+		t.Error("skipTunnelInterface(eth0) = true, want false")
+	}
+}
+
+func TestFilterTunnel(t *testing.T) {
+	candidates := []string{"2001::1", "2400:3200::1", "2002:c000:204::1", "2400:3200::5efe:c000:201", "2408:8000::2"}
+	got, err := filterTunnel(candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2400:3200::1", "2408:8000::2"}; !slices.Equal(got, want) {
+		t.Errorf("filterTunnel = %v, want %v", got, want)
+	}
+
+	_, err = filterTunnel([]string{"2001::1", "2002:c000:204::1"})
+	if !errors.Is(err, ErrNoPublicIPv6) {
+		t.Errorf("filterTunnel with only tunnel addresses: err = %v, want ErrNoPublicIPv6", err)
+	}
+}
+
+func TestFilterCandidatesTunnel(t *testing.T) {
+	candidates := []string{"2001::1", "2002:c000:204::1", "2400:3200::1"}
+
+	// 默认排除隧道地址
+	got, err := filterCandidates(candidates, config.Network{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2400:3200::1"}; !slices.Equal(got, want) {
+		t.Errorf("filterCandidates = %v, want %v", got, want)
+	}
+	if _, err := filterCandidates([]string{"2001::1", "2002:c000:204::1"}, config.Network{}); !errors.Is(err, ErrNoPublicIPv6) {
+		t.Errorf("filterCandidates with only tunnel addresses: err = %v, want ErrNoPublicIPv6", err)
+	}
+
+	// 开启 AllowTunnelAddresses 后保留隧道地址
+	got, err = filterCandidates(candidates, config.Network{AllowTunnelAddresses: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, candidates) {
+		t.Errorf("filterCandidates with allowTunnelAddresses = %v, want %v", got, candidates)
+	}
+}
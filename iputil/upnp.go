@@ -0,0 +1,259 @@
+package iputil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
+)
+
+// ssdpAddr SSDP 组播地址
+const ssdpAddr = "239.255.255.250:1900"
+
+// igdDeviceTypes 依次搜索的网关设备类型
+var igdDeviceTypes = []string{
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+}
+
+// ErrNoGateway 超时时间内没有网关响应
+var ErrNoGateway = errors.New("no gateway responded")
+
+// gatewayClient 访问网关的客户端，超时由调用方的 ctx 控制
+var gatewayClient = httpclient.New(0)
+
+// GetPublicIPv6ViaGateway 从本地网关获取公网 IPv6 地址，适用于本机不直接持有公网前缀的场景(如内网虚拟机)。
+// 配置了 RouterStatusURL 时从该页面中提取地址，否则通过 UPnP IGD 查询网关的外部地址
+func GetPublicIPv6ViaGateway(ctx context.Context, cfg config.Network) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.GatewayTimeout)*time.Second)
+	defer cancel()
+
+	if cfg.RouterStatusURL != "" {
+		return fetchRouterStatus(ctx, cfg)
+	}
+
+	location, err := discoverGateway(ctx)
+	if err != nil {
+		return "", err
+	}
+	service, err := findWANService(ctx, location)
+	if err != nil {
+		return "", fmt.Errorf("gateway %s: %v", location, err)
+	}
+	ip, err := externalIPAddress(ctx, service)
+	if err != nil {
+		return "", fmt.Errorf("gateway %s: %v", location, err)
+	}
+	if !IsValidIPv6(ip) {
+		return "", fmt.Errorf("gateway %s reported %q, not an IPv6 address", location, ip)
+	}
+	if err := checkPublic(ip); err != nil {
+		return "", err
+	}
+	return ip, nil
+}
+
+// discoverGateway 通过 SSDP 搜索网关，返回第一个响应的设备描述地址
+func discoverGateway(ctx context.Context) (string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return "", err
+	}
+	for _, st := range igdDeviceTypes {
+		msg := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: " + ssdpAddr + "\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n" +
+			"ST: " + st + "\r\n\r\n"
+		if _, err := conn.WriteTo([]byte(msg), dst); err != nil {
+			return "", fmt.Errorf("send SSDP search: %v", err)
+		}
+	}
+
+	deadline, _ := ctx.Deadline()
+	conn.SetReadDeadline(deadline)
+	buf := make([]byte, 2048)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return "", ErrNoGateway
+			}
+			return "", err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if location := resp.Header.Get("Location"); location != "" {
+			logrus.Debugf("UPnP gateway %s found at %s", from, location)
+			return location, nil
+		}
+	}
+}
+
+// upnpDevice 设备描述中的设备，服务可能位于任意层级的子设备中
+type upnpDevice struct {
+	Services []upnpService `xml:"serviceList>service"`
+	Devices  []upnpDevice  `xml:"deviceList>device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// findWANService 读取设备描述，返回 WANIPConnection 或 WANPPPConnection 服务，ControlURL 已转换为绝对地址
+func findWANService(ctx context.Context, location string) (*upnpService, error) {
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := gatewayClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("device description: unexpected status %d", resp.StatusCode)
+	}
+
+	var root struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&root); err != nil {
+		return nil, fmt.Errorf("device description: %v", err)
+	}
+	if root.URLBase != "" {
+		if u, err := url.Parse(root.URLBase); err == nil {
+			base = u
+		}
+	}
+
+	service := findService(root.Device)
+	if service == nil {
+		return nil, fmt.Errorf("no WANIPConnection or WANPPPConnection service")
+	}
+	control, err := base.Parse(service.ControlURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid control URL %q: %v", service.ControlURL, err)
+	}
+	service.ControlURL = control.String()
+	return service, nil
+}
+
+func findService(device upnpDevice) *upnpService {
+	for _, service := range device.Services {
+		if strings.Contains(service.ServiceType, ":WANIPConnection:") || strings.Contains(service.ServiceType, ":WANPPPConnection:") {
+			return &service
+		}
+	}
+	for _, child := range device.Devices {
+		if service := findService(child); service != nil {
+			return service
+		}
+	}
+	return nil
+}
+
+// externalIPAddress 调用服务的 GetExternalIPAddress 动作
+func externalIPAddress(ctx context.Context, service *upnpService) (string, error) {
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetExternalIPAddress xmlns:u="` + service.ServiceType + `"/></s:Body></s:Envelope>`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, service.ControlURL, strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+service.ServiceType+`#GetExternalIPAddress"`)
+
+	resp, err := gatewayClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GetExternalIPAddress: unexpected status %d", resp.StatusCode)
+	}
+
+	var envelope struct {
+		Address string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&envelope); err != nil {
+		return "", fmt.Errorf("GetExternalIPAddress: %v", err)
+	}
+	return strings.TrimSpace(envelope.Address), nil
+}
+
+// fetchRouterStatus 请求路由器状态页，从中提取符合地址范围的第一个公网 IPv6 地址，
+// 前缀形式(如 2001:db8:1::1/64)只取地址部分
+func fetchRouterStatus(ctx context.Context, cfg config.Network) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.RouterStatusURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := gatewayClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("%w: %v", ErrNoGateway, err)
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("router status: unexpected status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []string
+	for _, token := range strings.FieldsFunc(string(body), func(r rune) bool {
+		return !strings.ContainsRune("0123456789abcdefABCDEF:./", r)
+	}) {
+		token, _, _ = strings.Cut(token, "/")
+		if addr, err := netip.ParseAddr(token); err == nil && addr.Is6() && !addr.Is4In6() {
+			candidates = append(candidates, addr.String())
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no IPv6 address found in router status %s", cfg.RouterStatusURL)
+	}
+	candidates, err = filterReserved(candidates)
+	if err != nil {
+		return "", err
+	}
+	if candidates, err = filterScope(candidates, cfg.AddressScope); err != nil {
+		return "", fmt.Errorf("router status %s: %w", cfg.RouterStatusURL, err)
+	}
+	return candidates[0], nil
+}
@@ -1,128 +1,464 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"net"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
-	"github.com/tencentcloud/tencentcloud-sdk-go-intl-en/tencentcloud/common"
-	"github.com/tencentcloud/tencentcloud-sdk-go-intl-en/tencentcloud/common/profile"
-	dnspod "github.com/tencentcloud/tencentcloud-sdk-go-intl-en/tencentcloud/dnspod/v20210323"
 
 	"ddns-ipv6/config"
-	"ddns-ipv6/dns"
 	"ddns-ipv6/health"
-	"ddns-ipv6/iputil"
+	"ddns-ipv6/httpclient"
 	"ddns-ipv6/notification"
 	"ddns-ipv6/proxy"
 )
 
-func checkIPv6Connectivity() bool {
-	// 测试一些常用的 IPv6 地址
-	hosts := []string{
-		"2400:3200:baba::1",    // 阿里云 IPv6
-		"2400:da00:2::29",      // 腾讯云 IPv6
-		"2606:4700:4700::1111", // Cloudflare IPv6
+var (
+	configFile  = flag.String("config", "", "配置文件路径，支持 .yaml/.yml/.json/.toml，默认在当前目录查找 config.*")
+	once        = flag.Bool("once", false, "执行一次检测与更新后退出，失败时返回非零退出码")
+	dryRun      = flag.Bool("dry-run", false, "只记录将要执行的 DNS 变更，不实际调用接口")
+	printConfig = flag.Bool("print-config", false, "打印合并默认值与环境变量后的实际配置(隐藏敏感信息)后退出")
+	testNotify  = flag.Bool("test-notify", false, "向每个已启用的通知渠道发送一条测试通知，报告各渠道结果后退出")
+	check       = flag.Bool("check", false, "依次检查配置、IPv6 连通性、地址检测、DNS 服务商凭证与通知渠道，打印检查报告后退出，不修改 DNS 记录")
+)
+
+func main() {
+	flag.Parse()
+
+	// 读取配置文件
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		logrus.Fatalf("Failed to load config: %v", err)
+	}
+	applyFlags(cfg)
+	if *printConfig {
+		data, err := json.MarshalIndent(cfg.Redacted(), "", "  ")
+		if err != nil {
+			logrus.Fatalf("Failed to encode config: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	if *check {
+		if !runCheck(cfg) {
+			os.Exit(1)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		logrus.Fatalf("Invalid config: %v", err)
+	}
+	if err := setupLogging(cfg.Log); err != nil {
+		logrus.Fatalf("Failed to set up logging: %v", err)
+	}
+	httpclient.SetUserAgent(cfg.UserAgent)
+	if len(cfg.EnvOverrides) > 0 {
+		logrus.Printf("Config values overridden by environment: %s", strings.Join(cfg.EnvOverrides, ", "))
+	}
+	if *testNotify {
+		if !sendTestNotifications(cfg) {
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.DryRun {
+		logrus.Warn("Dry-run mode enabled, DNS records will not be modified")
+	}
+	if cfg.PidFile != "" {
+		if err := writePidFile(cfg.PidFile); err != nil {
+			logrus.Fatalf("Failed to write PID file: %v", err)
+		}
+		// 启动失败经由 logrus.Fatal 退出时同样删除 PID 文件
+		logrus.RegisterExitHandler(func() { removePidFile(cfg.PidFile) })
 	}
 
-	for _, host := range hosts {
-		conn, err := net.DialTimeout("tcp6", fmt.Sprintf("[%s]:443", host), 5*time.Second)
-		if err == nil {
-			conn.Close()
-			return true
+	// 每个更新目标使用独立的缓存、服务商、通知器与健康状态
+	var updaters []*updater
+	for _, target := range cfg.UpdateTargets() {
+		u, err := newUpdater(target)
+		if err != nil {
+			logrus.Fatalf("Failed to set up %s: %v", targetName(target), err)
 		}
+		updaters = append(updaters, u)
 	}
-	return false
-}
 
-func main() {
-	// 初始化组件
-	cache := dns.NewDNSCache()
-	healthCheck := health.NewHealthCheck()
+	// 收到 SIGINT/SIGTERM 时取消 ctx，优雅退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// 读取配置文件
-	cfg, err := config.LoadConfig()
+	proxies, err := startProxies(cfg)
 	if err != nil {
-		logrus.Fatalf("Failed to load config: %v", err)
+		logrus.Fatalf("Failed to start reverse proxy: %v", err)
 	}
 
-	// 判断是否需要启动 HTTP 反向代理
-	if cfg.Proxy.EnableHTTP {
-		go proxy.StartReverseProxy(cfg.Proxy.HTTPListenAddr, cfg.Proxy.HTTPTargetAddr)
+	var servers []*http.Server
+
+	// 判断是否需要启动健康检查服务
+	if cfg.Health.ListenAddr != "" {
+		targets := make([]health.Target, len(updaters))
+		for i, u := range updaters {
+			targets[i] = health.Target{
+				Name:  u.cfg.Name,
+				Check: u.healthCheck,
+				IPs:   u.cache,
+			}
+		}
+		actions := health.Actions{
+			Reload: func() (any, error) { return reloadConfig(updaters, proxies) },
+			Update: func() (any, error) { return updateNow(ctx, updaters) },
+		}
+		var proxyStats func() any
+		if cfg.Proxy.EnableHTTP || cfg.Proxy.EnableHTTPS {
+			proxyStats = func() any { return proxy.Connections() }
+		}
+		server, err := health.StartServer(cfg.Health, targets, actions, proxyStats)
+		if err != nil {
+			shutdown(servers, proxies, updaters)
+			logrus.Fatalf("Failed to start health server: %v", err)
+		}
+		servers = append(servers, server)
 	}
 
-	// 判断是否需要启动 HTTPS 反向代理
-	if cfg.Proxy.EnableHTTPS {
-		go proxy.StartReverseProxyTLS(cfg.Proxy.HTTPSListenAddr, cfg.Proxy.HTTPSTargetAddr, cfg.Proxy.CertFile, cfg.Proxy.KeyFile)
+	logrus.Printf("Starting IPv6 DDNS service with %d target(s)...", len(updaters))
+	if !startupWait(ctx, cfg) {
+		logrus.Println("Interrupted during startup delay, shutting down...")
+		shutdown(servers, proxies, updaters)
+		removePidFile(cfg.PidFile)
+		return
 	}
 
-	// 创建腾讯云客户端
-	logrus.Println("Creating Tencent Cloud client...")
-	credential := common.NewCredential(
-		cfg.Tencent.SecretId,
-		cfg.Tencent.SecretKey,
-	)
-	cpf := profile.NewClientProfile()
-	client, err := dnspod.NewClient(credential, "ap-guangzhou", cpf)
-	if err != nil {
-		logrus.Fatalf("Failed to create DNSPod client: %v", err)
+	// 以实际发布的记录值代替可能过期的缓存
+	for _, u := range updaters {
+		if u.cfg.ReconcileOnStart {
+			u.reconcile(ctx)
+		}
 	}
-	logrus.Println("Tencent Cloud client created successfully.")
 
-	logrus.Printf("Starting IPv6 DDNS service...")
+	// 单次模式，适合由 cron 或 systemd timer 调度
+	if *once {
+		var errs []error
+		for _, u := range updaters {
+			if _, err := u.run(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", u.name(), err))
+			}
+			if until, ok := u.deferred(); ok {
+				logrus.Warnf("Update for %s deferred until %s by quiet hours, records were not changed", u.name(), until.Format("15:04"))
+			}
+		}
+		shutdown(servers, proxies, updaters)
+		removePidFile(cfg.PidFile)
+		if err := errors.Join(errs...); err != nil {
+			logrus.Errorf("Update failed: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 收到 SIGHUP 时重新加载配置
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadConfig(updaters, proxies)
+		}
+	}()
 
-	// 检查IPv6连接
-	if !checkIPv6Connectivity() {
-		logrus.Println("IPv6 connectivity check failed, sending notification...")
-		notification.SendNotification(cfg.Email,
-			"IPv6 DDNS 更新失败",
-			"无法连接到公共 IPv6 地址")
+	// 每个目标按各自的间隔定期检查并更新IP
+	var wg sync.WaitGroup
+	for _, u := range updaters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u.loop(ctx)
+		}()
 	}
-	// 定期检查并更新IP
-	for {
-		logrus.Println("Checking local IPv6 address...")
-		ipv6, err := iputil.GetLocalIPv6()
+	wg.Wait()
+
+	logrus.Println("Shutting down...")
+	removeRecords(updaters)
+	shutdown(servers, proxies, updaters)
+	removePidFile(cfg.PidFile)
+}
+
+// startupWait 首次检测前等待 startupDelay 加上 0 到 startupJitter 之间的随机秒数，ctx 取消时返回 false
+func startupWait(ctx context.Context, cfg *config.Config) bool {
+	delay := time.Duration(cfg.StartupDelay) * time.Second
+	if cfg.StartupJitter > 0 {
+		delay += rand.N(time.Duration(cfg.StartupJitter) * time.Second)
+	}
+	if delay <= 0 {
+		return true
+	}
+
+	logrus.Printf("Waiting %s before the first check", delay.Round(time.Second))
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// removeRecords 退出前删除开启了 removeRecordOnExit 的目标发布的记录，单次模式不删除
+func removeRecords(updaters []*updater) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, u := range updaters {
+		u.removeRecords(ctx)
+	}
+}
+
+// shutdown 关闭所有 HTTP 服务，发送完待发通知并写入缓存
+func shutdown(servers []*http.Server, proxies *proxyServers, updaters []*updater) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	proxies.shutdown(ctx)
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			logrus.Errorf("Failed to shut down server on %s: %v", server.Addr, err)
+		}
+	}
+	for _, u := range updaters {
+		if err := u.notifier.Close(ctx); err != nil {
+			logrus.Errorf("Failed to flush notifications for %s: %v", u.name(), err)
+		}
+		if err := u.cache.Flush(); err != nil {
+			logrus.Errorf("Failed to flush cache for %s: %v", u.name(), err)
+		}
+	}
+}
+
+// sendTestNotifications 通过每个目标启用的各个通知渠道同步发送测试通知并打印结果，全部成功时返回 true
+func sendTestNotifications(cfg *config.Config) bool {
+	ok := true
+	for _, target := range cfg.UpdateTargets() {
+		err := sendTestNotification(target, func(channel string, err error) {
+			label := channel
+			if target.Name != "" {
+				label = target.Name + "/" + channel
+			}
+			if err != nil {
+				ok = false
+				fmt.Printf("%s: FAILED: %v\n", label, err)
+				return
+			}
+			fmt.Printf("%s: ok\n", label)
+		})
 		if err != nil {
-			logrus.Printf("Failed to get IPv6 address: %v", err)
-			if healthCheck.RecordError() >= 3 {
-				logrus.Println("Error threshold reached, sending notification...")
-				notification.SendNotification(cfg.Email,
-					"IPv6 DDNS 更新失败",
-					fmt.Sprintf("获取IPv6地址失败: %v", err))
+			ok = false
+			fmt.Printf("%s: FAILED: %v\n", targetName(target), err)
+		}
+	}
+	return ok
+}
+
+// sendTestNotification 通过目标启用的各个通知渠道同步发送测试通知，每个渠道的结果交给 report；
+// 通知模板无效时返回错误
+func sendTestNotification(target *config.Config, report func(channel string, err error)) error {
+	templates, err := notification.NewTemplates(target.Notifications.Templates)
+	if err != nil {
+		return err
+	}
+	title, body := templates.Render(notification.EventTest, notification.TemplateData{
+		Target:   target.Name,
+		Provider: target.ProviderName(),
+		Records:  target.Domain.Hostnames(),
+	})
+	msg := notification.Message{
+		Title:    title,
+		Body:     body,
+		Hostname: strings.Join(target.Domain.Hostnames(), ","),
+		Time:     time.Now(),
+	}
+	for _, channel := range target.Notifications.EnabledChannels() {
+		n, err := notification.NewChannel(channel, *target)
+		if err == nil {
+			// 渠道返回的错误已带渠道名，去掉以免与渠道标签重复
+			if err = n.Notify(msg); err != nil {
+				err = errors.Unwrap(err)
 			}
-			time.Sleep(time.Duration(cfg.CheckInterval) * time.Second)
-			continue
 		}
+		report(channel, err)
+	}
+	return nil
+}
 
-		logrus.Printf("Local IPv6 address: %s", ipv6)
+// applyFlags 将命令行参数合并到配置及各更新目标中，命令行优先
+func applyFlags(cfg *config.Config) {
+	for _, c := range append([]*config.Config{cfg}, cfg.Targets...) {
+		if *dryRun {
+			c.DryRun = true
+		}
+		// 单次模式无法跨轮次累计检测次数，地址变化时直接更新
+		if *once {
+			c.StabilityChecks = 1
+		}
+	}
+}
 
-		// 检查缓存，避免重复更新
-		cachedIP, _ := cache.GetIP()
-		if cachedIP == ipv6 {
-			logrus.Printf("IP未变化，跳过更新")
-			time.Sleep(time.Duration(cfg.CheckInterval) * time.Second)
-			continue
+// logFile 当前写入的日志文件及其配置，未配置 log.file 时为 nil
+var (
+	logFile    *rotatingFile
+	logFileCfg config.Log
+)
+
+// setupLogging 按配置设置日志格式、级别与输出，并将标准库 log 的输出转到 logrus。
+// 日志文件配置变化时切换到新文件，打开失败时保留当前输出
+func setupLogging(cfg config.Log) error {
+	if cfg.Format == "json" {
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	} else {
+		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	}
+
+	level := logrus.InfoLevel
+	if cfg.Level != "" {
+		level, _ = logrus.ParseLevel(cfg.Level)
+	}
+	logrus.SetLevel(level)
+
+	log.SetFlags(0)
+	log.SetOutput(logrus.StandardLogger().Writer())
+	return setupLogOutput(cfg)
+}
+
+// setupLogOutput 按 log.file、log.maxSize 与 log.maxBackups 设置日志输出
+func setupLogOutput(cfg config.Log) error {
+	if cfg.File == logFileCfg.File && cfg.MaxSize == logFileCfg.MaxSize && cfg.MaxBackups == logFileCfg.MaxBackups {
+		return nil
+	}
+	var next *rotatingFile
+	if cfg.File != "" {
+		var err error
+		if next, err = openRotatingFile(cfg.File, cfg.MaxSize, cfg.MaxBackups); err != nil {
+			return fmt.Errorf("open log file: %v", err)
 		}
+		logrus.SetOutput(next)
+	} else {
+		logrus.SetOutput(os.Stderr)
+	}
+	if logFile != nil {
+		logFile.Close()
+	}
+	logFile, logFileCfg = next, cfg
+	return nil
+}
 
-		logrus.Println("Updating DNS record...")
-		// 使用重试机制更新DNS记录
-		err = dns.UpdateDNSRecordWithRetry(client, *cfg, ipv6)
+// updateResult 一个目标立即更新的结果
+type updateResult struct {
+	Target  string `json:"target"`
+	Changed bool   `json:"changed"`
+	IPv6    string `json:"ipv6,omitempty"`
+	IPv4    string `json:"ipv4,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// DeferredUntil 更新因静默时段推迟时为时段结束时间(HH:MM)
+	DeferredUntil string `json:"deferredUntil,omitempty"`
+}
+
+// updateNow 在定时检测之外立即对每个目标执行一次检测与更新，与定时任务共用缓存与健康状态
+func updateNow(ctx context.Context, updaters []*updater) ([]updateResult, error) {
+	logrus.Println("Running update on request...")
+	results := make([]updateResult, len(updaters))
+	var errs []error
+	for i, u := range updaters {
+		changed, err := u.run(ctx)
+		results[i] = updateResult{Target: u.name(), Changed: changed}
+		results[i].IPv6, _ = u.cache.GetIP("AAAA")
+		results[i].IPv4, _ = u.cache.GetIP("A")
+		if until, ok := u.deferred(); ok {
+			results[i].DeferredUntil = until.Format("15:04")
+			logrus.Printf("Update for %s deferred until %s by quiet hours", u.name(), until.Format("15:04"))
+		}
 		if err != nil {
-			logrus.Printf("Failed to update DNS record: %v", err)
-			if healthCheck.RecordError() >= 3 {
-				logrus.Println("Error threshold reached, sending notification...")
-				notification.SendNotification(cfg.Email,
-					"IPv6 DDNS 更新失败",
-					fmt.Sprintf("更新DNS记录失败: %v", err))
-			}
-		} else {
-			logrus.Printf("Successfully updated DNS record: %s.%s -> %s",
-				cfg.Domain.SubDomain, cfg.Domain.Domain, ipv6)
-			cache.UpdateIP(ipv6)
-			healthCheck.RecordSuccess()
+			results[i].Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", u.name(), err))
 		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// reloadSummary 一次重新加载的结果，配置项带有目标名称前缀(配置了 targets 时)
+type reloadSummary struct {
+	Changed         []string `json:"changed"`
+	RestartRequired []string `json:"restartRequired,omitempty"`
+}
 
-		time.Sleep(time.Duration(cfg.CheckInterval) * time.Second)
+// reloadMu 串行执行 SIGHUP 与 /reload 触发的重新加载
+var reloadMu sync.Mutex
+
+// reloadConfig 重新读取并校验配置，失败时保留当前配置。
+// 各目标按名称匹配新配置，新增或删除目标需重启。
+func reloadConfig(updaters []*updater, proxies *proxyServers) (*reloadSummary, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	logrus.Println("Reloading config...")
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		logrus.Errorf("Failed to reload config: %v", err)
+		return nil, err
+	}
+	applyFlags(cfg)
+	if err := cfg.Validate(); err != nil {
+		logrus.Errorf("Invalid config, keeping current settings: %v", err)
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	summary := &reloadSummary{Changed: []string{}}
+	targets := make(map[string]*config.Config)
+	for _, target := range cfg.UpdateTargets() {
+		targets[target.Name] = target
+	}
+	if len(targets) != len(updaters) {
+		logrus.Warnf("Number of targets changed from %d to %d, restart to add or remove targets", len(updaters), len(targets))
+	}
+	for _, u := range updaters {
+		target, ok := targets[u.cfg.Name]
+		if !ok {
+			logrus.Warnf("Target %s was removed from the config, restart to stop it", u.name())
+			continue
+		}
+		changed := config.Changed(u.cfg, target)
+		restart := config.RestartRequired(u.cfg, target)
+		if err := u.reload(target); err != nil {
+			logrus.Errorf("Failed to apply config for %s, keeping current settings: %v", u.name(), err)
+			return summary, fmt.Errorf("apply config for %s: %w", u.name(), err)
+		}
+		summary.Changed = append(summary.Changed, prefixed(target.Name, changed)...)
+		summary.RestartRequired = append(summary.RestartRequired, prefixed(target.Name, restart)...)
+	}
+	if _, err := proxies.reload(cfg); err != nil {
+		return summary, err
+	}
+	if err := setupLogging(cfg.Log); err != nil {
+		logrus.Errorf("Failed to apply log settings, keeping current output: %v", err)
+	}
+	httpclient.SetUserAgent(cfg.UserAgent)
+	logrus.Println("Config reloaded.")
+	return summary, nil
+}
+
+// prefixed 为配置项加上目标名称前缀，未命名的单一目标保持不变
+func prefixed(name string, fields []string) []string {
+	if name == "" {
+		return fields
+	}
+	result := make([]string, len(fields))
+	for i, field := range fields {
+		result[i] = name + "." + field
 	}
+	return result
 }
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry 所有指标注册在同一个 registry 中，通过 Handler 统一暴露
+var Registry = prometheus.NewRegistry()
+
+var (
+	// 更新相关的指标均带有 target 标签(更新目标的名称，未配置 targets 时为空)，各目标互不覆盖
+	updateSuccess = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ddns_update_success_total",
+		Help: "Number of successful DNS updates by target.",
+	}, []string{"target"})
+	updateFailure = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ddns_update_failure_total",
+		Help: "Number of failed detection or DNS update attempts by target.",
+	}, []string{"target"})
+	ipChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ddns_ip_changes_total",
+		Help: "Number of published IP changes by target and record type.",
+	}, []string{"target", "type"})
+	consecutiveErrors = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ddns_consecutive_errors",
+		Help: "Current number of consecutive errors by target.",
+	}, []string{"target"})
+	lastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ddns_last_success_timestamp_seconds",
+		Help: "Unix timestamp of the last successful update by target.",
+	}, []string{"target"})
+	publishedIP = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ddns_published_ip_info",
+		Help: "Currently published IP address by target and record type, exposed as a label.",
+	}, []string{"target", "type", "ip"})
+	proxyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ddns_proxy_requests_total",
+		Help: "Number of reverse proxy requests by listen address, method and status class.",
+	}, []string{"listen", "method", "code"})
+	proxyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ddns_proxy_request_duration_seconds",
+		Help:    "Reverse proxy request latency by listen address and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"listen", "method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		updateSuccess,
+		updateFailure,
+		ipChanges,
+		consecutiveErrors,
+		lastSuccess,
+		publishedIP,
+		proxyRequests,
+		proxyDuration,
+	)
+}
+
+// Handler 返回 Prometheus 文本格式的 /metrics 处理器
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveSuccess 记录目标的一次成功更新
+func ObserveSuccess(target string) {
+	updateSuccess.WithLabelValues(target).Inc()
+	lastSuccess.WithLabelValues(target).Set(float64(time.Now().Unix()))
+}
+
+// ObserveRecovered 目标本轮检测更新全部成功，连续错误数清零
+func ObserveRecovered(target string) {
+	consecutiveErrors.WithLabelValues(target).Set(0)
+}
+
+// ObserveFailure 记录目标的一次失败，consecutive 为当前连续错误数
+func ObserveFailure(target string, consecutive int) {
+	updateFailure.WithLabelValues(target).Inc()
+	consecutiveErrors.WithLabelValues(target).Set(float64(consecutive))
+}
+
+// ObserveIPChange 记录目标已发布地址的变更
+func ObserveIPChange(target, recordType, ip string) {
+	ipChanges.WithLabelValues(target, recordType).Inc()
+	ObservePublishedIP(target, recordType, ip)
+}
+
+// ObservePublishedIP 设置目标当前发布的地址，启动时从缓存恢复、地址未变化时每轮刷新，不计入变更次数
+func ObservePublishedIP(target, recordType, ip string) {
+	publishedIP.DeletePartialMatch(prometheus.Labels{"target": target, "type": recordType})
+	publishedIP.WithLabelValues(target, recordType, ip).Set(1)
+}
+
+// ObserveProxyRequest 记录一次反向代理请求，状态码按类别(2xx、4xx 等)计数
+func ObserveProxyRequest(listen, method string, status int, duration time.Duration) {
+	code := strconv.Itoa(status/100) + "xx"
+	proxyRequests.WithLabelValues(listen, method, code).Inc()
+	proxyDuration.WithLabelValues(listen, method).Observe(duration.Seconds())
+}
@@ -0,0 +1,72 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AsyncNotifier 在后台协程中依次发送通知，避免缓慢的通知渠道阻塞地址检测与 DNS 更新
+type AsyncNotifier struct {
+	notifier Notifier
+	queue    chan Message
+	done     chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsyncNotifier 创建带缓冲队列的异步通知器，队列满时新通知被丢弃
+func NewAsyncNotifier(notifier Notifier, queueSize int) *AsyncNotifier {
+	n := &AsyncNotifier{
+		notifier: notifier,
+		queue:    make(chan Message, queueSize),
+		done:     make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+// Notify 将通知放入队列后立即返回，发送结果只记录日志
+func (n *AsyncNotifier) Notify(msg Message) error {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.closed {
+		return fmt.Errorf("notifier closed, dropped %q", msg.Title)
+	}
+	select {
+	case n.queue <- msg:
+		return nil
+	default:
+		return fmt.Errorf("notification queue full, dropped %q", msg.Title)
+	}
+}
+
+// Close 停止接收新通知，并在 ctx 结束前等待队列中的通知发送完毕
+func (n *AsyncNotifier) Close(ctx context.Context) error {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	select {
+	case <-n.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d notifications not sent: %w", len(n.queue), ctx.Err())
+	}
+}
+
+func (n *AsyncNotifier) run() {
+	defer close(n.done)
+	for msg := range n.queue {
+		if err := n.notifier.Notify(msg); err != nil {
+			logrus.Errorf("Failed to send notification %q: %v", msg.Title, err)
+		}
+	}
+}
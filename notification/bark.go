@@ -0,0 +1,70 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
+)
+
+// BarkNotifier 通过 Bark 向 iOS 设备推送通知
+type BarkNotifier struct {
+	serverURL string
+	deviceKey string
+	sound     string
+	group     string
+	client    *http.Client
+}
+
+func NewBarkNotifier(cfg config.Bark) *BarkNotifier {
+	return &BarkNotifier{
+		serverURL: strings.TrimRight(cfg.ServerURL, "/"),
+		deviceKey: cfg.DeviceKey,
+		sound:     cfg.Sound,
+		group:     cfg.Group,
+		client:    httpclient.New(10 * time.Second),
+	}
+}
+
+func (n *BarkNotifier) Notify(msg Message) error {
+	endpoint := fmt.Sprintf("%s/%s/%s/%s", n.serverURL,
+		url.PathEscape(n.deviceKey), url.PathEscape(msg.Title), url.PathEscape(msg.Body))
+
+	query := url.Values{}
+	if n.sound != "" {
+		query.Set("sound", n.sound)
+	}
+	if n.group != "" {
+		query.Set("group", n.group)
+	}
+	if msg.Urgent {
+		// 时效性通知可在专注模式下显示
+		query.Set("level", "timeSensitive")
+	}
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	resp, err := send(n.client, msg, http.MethodGet, endpoint, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode response (status %d): %v", resp.StatusCode, err)
+	}
+	if result.Code != http.StatusOK {
+		return fmt.Errorf("%s (code %d)", result.Message, result.Code)
+	}
+	return nil
+}
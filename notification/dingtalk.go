@@ -0,0 +1,79 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
+)
+
+// DingTalkNotifier 通过钉钉群机器人发送通知
+type DingTalkNotifier struct {
+	webhookURL string
+	secret     string
+	client     *http.Client
+}
+
+func NewDingTalkNotifier(cfg config.DingTalk) *DingTalkNotifier {
+	return &DingTalkNotifier{
+		webhookURL: cfg.WebhookURL,
+		secret:     cfg.Secret,
+		client:     httpclient.New(10 * time.Second),
+	}
+}
+
+func (n *DingTalkNotifier) Notify(msg Message) error {
+	payload, err := json.Marshal(map[string]any{
+		"msgtype": "text",
+		"text":    map[string]string{"content": msg.Title + "\n\n" + msg.Body},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := send(n.client, msg, http.MethodPost, n.signedURL(time.Now()), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode response (status %d): %v", resp.StatusCode, err)
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("%s (errcode %d)", result.ErrMsg, result.ErrCode)
+	}
+	return nil
+}
+
+// signedURL 开启加签时在 webhook 地址后附加 timestamp 与 sign 参数
+func (n *DingTalkNotifier) signedURL(now time.Time) string {
+	if n.secret == "" {
+		return n.webhookURL
+	}
+
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(n.secret))
+	mac.Write([]byte(timestamp + "\n" + n.secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	sep := "?"
+	if strings.Contains(n.webhookURL, "?") {
+		sep = "&"
+	}
+	return n.webhookURL + sep + "timestamp=" + timestamp + "&sign=" + url.QueryEscape(sign)
+}
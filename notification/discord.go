@@ -0,0 +1,86 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
+)
+
+// discordMaxBody Discord 消息内容的长度上限
+const discordMaxBody = 2000
+
+// embed 颜色: 成功为绿色，故障为红色
+const (
+	discordColorSuccess = 0x2ecc71
+	discordColorFailure = 0xe74c3c
+)
+
+// DiscordNotifier 通过 Discord webhook 发送通知
+type DiscordNotifier struct {
+	webhookURL string
+	username   string
+	avatarURL  string
+	client     *http.Client
+}
+
+func NewDiscordNotifier(cfg config.Discord) *DiscordNotifier {
+	return &DiscordNotifier{
+		webhookURL: cfg.WebhookURL,
+		username:   cfg.Username,
+		avatarURL:  cfg.AvatarURL,
+		client:     httpclient.New(10 * time.Second),
+	}
+}
+
+func (n *DiscordNotifier) Notify(msg Message) error {
+	color := discordColorSuccess
+	if msg.Failure {
+		color = discordColorFailure
+	}
+	payload := map[string]any{
+		"embeds": []map[string]any{{
+			"title":       msg.Title,
+			"description": truncate(msg.Body, discordMaxBody),
+			"color":       color,
+			"timestamp":   msg.Time.Format(time.RFC3339),
+		}},
+	}
+	if n.username != "" {
+		payload["username"] = n.username
+	}
+	if n.avatarURL != "" {
+		payload["avatar_url"] = n.avatarURL
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := send(n.client, msg, http.MethodPost, n.webhookURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 成功时返回 204 No Content
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+// truncate 将 s 截断到最多 limit 个字符，截断时以省略号结尾
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
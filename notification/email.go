@@ -0,0 +1,103 @@
+package notification
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+
+	"ddns-ipv6/config"
+)
+
+// EmailNotifier 邮件通知
+type EmailNotifier struct {
+	cfg config.Email
+}
+
+func NewEmailNotifier(cfg config.Email) *EmailNotifier {
+	return &EmailNotifier{cfg: cfg}
+}
+
+func (n *EmailNotifier) Notify(msg Message) error {
+	return SendNotification(n.cfg, msg.Title, msg.Body)
+}
+
+// SendNotification 发送邮件通知，按 Encryption 选择加密方式，连接与整个发送过程分别受 DialTimeout 和 SendTimeout 限制
+func SendNotification(emailCfg config.Email, subject, body string) error {
+	msg := fmt.Sprintf("From: %s\r\n"+
+		"To: %s\r\n"+
+		"Subject: %s\r\n"+
+		"\r\n"+
+		"%s\r\n", emailCfg.Username, emailCfg.Recipient, subject, body)
+
+	addr := net.JoinHostPort(emailCfg.SMTPServer, fmt.Sprint(emailCfg.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: emailCfg.SMTPServer}
+	dialer := &net.Dialer{Timeout: time.Duration(emailCfg.DialTimeout) * time.Second}
+
+	var conn net.Conn
+	var err error
+	if emailCfg.Encryption == "tls" {
+		// 隐式 TLS(通常为 465 端口)，连接建立即握手
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+		if err != nil {
+			return fmt.Errorf("TLS connection to %s failed: %w", addr, err)
+		}
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("connect to %s: %w", addr, err)
+		}
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(time.Duration(emailCfg.SendTimeout) * time.Second)); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, emailCfg.SMTPServer)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	switch emailCfg.Encryption {
+	case "starttls":
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not support STARTTLS", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS handshake with %s failed: %w", addr, err)
+		}
+	case "":
+		// 未指定时与 smtp.SendMail 一致：服务器支持时升级为 STARTTLS
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS handshake with %s failed: %w", addr, err)
+			}
+		}
+	}
+	if ok, _ := client.Extension("AUTH"); ok {
+		auth := smtp.PlainAuth("", emailCfg.Username, emailCfg.Password, emailCfg.SMTPServer)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(emailCfg.Username); err != nil {
+		return err
+	}
+	if err := client.Rcpt(emailCfg.Recipient); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,60 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"ddns-ipv6/config"
+)
+
+// ExecNotifier 执行外部命令发送通知，标题与正文作为最后两个参数，同时通过环境变量传递
+type ExecNotifier struct {
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+func NewExecNotifier(cfg config.Exec) *ExecNotifier {
+	return &ExecNotifier{
+		command: cfg.Command,
+		args:    cfg.Args,
+		timeout: time.Duration(cfg.Timeout) * time.Second,
+	}
+}
+
+// Notify 执行命令并等待其退出，退出码非零或超时视为发送失败，错误中附带 stderr 输出
+func (n *ExecNotifier) Notify(msg Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+
+	args := append(append([]string{}, n.args...), msg.Title, msg.Body)
+	cmd := exec.CommandContext(ctx, n.command, args...)
+	cmd.Env = append(os.Environ(),
+		"DDNS_TITLE="+msg.Title,
+		"DDNS_BODY="+msg.Body,
+		"DDNS_IP="+msg.IP,
+		"DDNS_OLD_IP="+msg.OldIP,
+		"DDNS_HOSTNAME="+msg.Hostname,
+		fmt.Sprintf("DDNS_FAILURE=%t", msg.Failure),
+		fmt.Sprintf("DDNS_URGENT=%t", msg.Urgent),
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s timed out after %s", n.command, n.timeout)
+	}
+	if err != nil {
+		if output := bytes.TrimSpace(stderr.Bytes()); len(output) > 0 {
+			return fmt.Errorf("%s: %w: %s", n.command, err, output)
+		}
+		return fmt.Errorf("%s: %w", n.command, err)
+	}
+	return nil
+}
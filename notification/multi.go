@@ -0,0 +1,41 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MultiNotifier 同时向多个渠道发送通知，单个渠道失败不影响其他渠道
+type MultiNotifier struct {
+	notifiers []Notifier
+}
+
+func NewMultiNotifier(notifiers ...Notifier) *MultiNotifier {
+	return &MultiNotifier{notifiers: notifiers}
+}
+
+func (m *MultiNotifier) Notify(msg Message) error {
+	errs := make([]error, len(m.notifiers))
+
+	var wg sync.WaitGroup
+	for i, n := range m.notifiers {
+		wg.Add(1)
+		go func(i int, n Notifier) {
+			defer wg.Done()
+			errs[i] = n.Notify(msg)
+		}(i, n)
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d/%d notification channels failed: %s", len(failed), len(m.notifiers), strings.Join(failed, "; "))
+	}
+	return nil
+}
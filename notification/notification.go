@@ -1,29 +1,117 @@
 package notification
 
 import (
+	"context"
 	"fmt"
-	"net/smtp"
+	"io"
+	"net/http"
+	"time"
 
 	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
 )
 
-// SendNotification 发送邮件通知
-func SendNotification(emailCfg config.Email, subject, body string) error {
-	auth := smtp.PlainAuth("", emailCfg.Username, emailCfg.Password, emailCfg.SMTPServer)
-
-	msg := fmt.Sprintf("From: %s\r\n"+
-		"To: %s\r\n"+
-		"Subject: %s\r\n"+
-		"\r\n"+
-		"%s\r\n", emailCfg.Username, emailCfg.Recipient, subject, body)
-
-	err := smtp.SendMail(
-		fmt.Sprintf("%s:%d", emailCfg.SMTPServer, emailCfg.SMTPPort),
-		auth,
-		emailCfg.Username,
-		[]string{emailCfg.Recipient},
-		[]byte(msg),
-	)
-
-	return err
+// Notifier 通知渠道接口
+type Notifier interface {
+	Notify(msg Message) error
+}
+
+// Message 通知内容，除标题和正文外附带事件相关的地址信息，供 webhook 等渠道使用
+type Message struct {
+	Title string
+	Body  string
+	// IP 本次事件涉及的地址，可能为空
+	IP string
+	// OldIP 地址变更前的值，仅变更通知时设置
+	OldIP string
+	// Hostname 相关的域名记录，多个时以逗号分隔
+	Hostname string
+	Time     time.Time
+	// Failure 故障类通知，渠道可据此使用不同的样式
+	Failure bool
+	// Urgent 需要立即处理的故障，支持优先级的渠道(ntfy、Bark)以最高优先级发送
+	Urgent bool
+	// RequestID 触发通知的检测周期的请求ID，HTTP 渠道通过 X-Request-ID 传递
+	RequestID string
+}
+
+// context 返回携带消息请求ID的 context
+func (m Message) context() context.Context {
+	return httpclient.WithRequestID(context.Background(), m.RequestID)
+}
+
+// send 发送 HTTP 请求，带上消息的请求ID；contentType 为空时不设置 Content-Type
+func send(client *http.Client, msg Message, method, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(msg.context(), method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return client.Do(req)
+}
+
+// New 根据配置创建通知渠道，配置了多个渠道时同时发送到所有渠道
+func New(cfg config.Config) (Notifier, error) {
+	channels := cfg.Notifications.EnabledChannels()
+
+	var notifiers []Notifier
+	for _, channel := range channels {
+		n, err := NewChannel(channel, cfg)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+
+	if len(notifiers) == 1 {
+		return notifiers[0], nil
+	}
+	return NewMultiNotifier(notifiers...), nil
+}
+
+// NewChannel 创建单个通知渠道，返回的错误会带上渠道名
+func NewChannel(channel string, cfg config.Config) (Notifier, error) {
+	var n Notifier
+	switch channel {
+	case "email":
+		n = NewEmailNotifier(cfg.Email)
+	case "telegram":
+		n = NewTelegramNotifier(cfg.Telegram)
+	case "webhook":
+		var err error
+		if n, err = NewWebhookNotifier(cfg.Webhook); err != nil {
+			return nil, err
+		}
+	case "bark":
+		n = NewBarkNotifier(cfg.Bark)
+	case "dingtalk":
+		n = NewDingTalkNotifier(cfg.DingTalk)
+	case "wecom":
+		n = NewWeComNotifier(cfg.WeCom)
+	case "discord":
+		n = NewDiscordNotifier(cfg.Discord)
+	case "slack":
+		n = NewSlackNotifier(cfg.Slack)
+	case "ntfy":
+		n = NewNtfyNotifier(cfg.Ntfy)
+	case "exec":
+		n = NewExecNotifier(cfg.Exec)
+	default:
+		return nil, fmt.Errorf("unknown notification channel %q", channel)
+	}
+	return &namedNotifier{name: channel, Notifier: n}, nil
+}
+
+type namedNotifier struct {
+	name string
+	Notifier
+}
+
+func (n *namedNotifier) Notify(msg Message) error {
+	if err := n.Notifier.Notify(msg); err != nil {
+		return fmt.Errorf("%s: %w", n.name, err)
+	}
+	return nil
 }
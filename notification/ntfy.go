@@ -0,0 +1,65 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
+)
+
+// NtfyNotifier 向 ntfy 服务的主题推送通知
+type NtfyNotifier struct {
+	topicURL string
+	priority string
+	tags     string
+	token    string
+	client   *http.Client
+}
+
+func NewNtfyNotifier(cfg config.Ntfy) *NtfyNotifier {
+	return &NtfyNotifier{
+		topicURL: strings.TrimRight(cfg.ServerURL, "/") + "/" + cfg.Topic,
+		priority: cfg.Priority,
+		tags:     strings.Join(cfg.Tags, ","),
+		token:    cfg.Token,
+		client:   httpclient.New(10 * time.Second),
+	}
+}
+
+func (n *NtfyNotifier) Notify(msg Message) error {
+	req, err := http.NewRequestWithContext(msg.context(), http.MethodPost, n.topicURL, strings.NewReader(msg.Body))
+	if err != nil {
+		return err
+	}
+	// 请求头只能安全地传递 ASCII，中文标题按 RFC 2047 编码，ntfy 会自动解码
+	req.Header.Set("Title", mime.BEncoding.Encode("utf-8", msg.Title))
+	if msg.Urgent {
+		req.Header.Set("Priority", "urgent")
+	} else if n.priority != "" {
+		req.Header.Set("Priority", n.priority)
+	}
+	if n.tags != "" {
+		req.Header.Set("Tags", n.tags)
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
+)
+
+// SlackNotifier 通过 Slack incoming webhook 发送通知
+type SlackNotifier struct {
+	webhookURL string
+	useBlocks  bool
+	client     *http.Client
+}
+
+func NewSlackNotifier(cfg config.Slack) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL: cfg.WebhookURL,
+		useBlocks:  cfg.UseBlocks,
+		client:     httpclient.New(10 * time.Second),
+	}
+}
+
+func (n *SlackNotifier) Notify(msg Message) error {
+	text := msg.Title + "\n" + msg.Body
+	payload := map[string]any{"text": text}
+	if n.useBlocks {
+		payload["blocks"] = slackBlocks(msg)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := send(n.client, msg, http.MethodPost, n.webhookURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 成功时响应体为 ok，失败时为错误原因，如 invalid_payload
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if result := string(bytes.TrimSpace(respBody)); result != "ok" {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, result)
+	}
+	return nil
+}
+
+// slackBlocks 生成包含域名与新旧地址的消息布局
+func slackBlocks(msg Message) []map[string]any {
+	blocks := []map[string]any{
+		{"type": "header", "text": map[string]string{"type": "plain_text", "text": msg.Title}},
+		{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": msg.Body}},
+	}
+
+	var fields []map[string]string
+	for _, field := range []struct{ name, value string }{
+		{"Hostname", msg.Hostname},
+		{"Old IP", msg.OldIP},
+		{"New IP", msg.IP},
+	} {
+		if field.value != "" {
+			fields = append(fields, map[string]string{"type": "mrkdwn", "text": "*" + field.name + "*\n" + field.value})
+		}
+	}
+	if len(fields) > 0 {
+		blocks = append(blocks, map[string]any{"type": "section", "fields": fields})
+	}
+	return blocks
+}
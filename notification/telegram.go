@@ -0,0 +1,57 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
+)
+
+const telegramAPI = "https://api.telegram.org"
+
+// TelegramNotifier 通过 Telegram Bot 发送通知
+type TelegramNotifier struct {
+	botToken string
+	chatID   string
+	client   *http.Client
+}
+
+func NewTelegramNotifier(cfg config.Telegram) *TelegramNotifier {
+	return &TelegramNotifier{
+		botToken: cfg.BotToken,
+		chatID:   cfg.ChatID,
+		client:   httpclient.New(10 * time.Second),
+	}
+}
+
+func (n *TelegramNotifier) Notify(msg Message) error {
+	payload, err := json.Marshal(map[string]string{
+		"chat_id": n.chatID,
+		"text":    msg.Title + "\n\n" + msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := send(n.client, msg, http.MethodPost, telegramAPI+"/bot"+n.botToken+"/sendMessage", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode response (status %d): %v", resp.StatusCode, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, result.Description)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"ddns-ipv6/config"
+)
+
+// 通知事件，对应 notifications.templates 中的各项
+const (
+	EventChange       = "change"
+	EventRecovered    = "recovered"
+	EventConnectivity = "connectivity"
+	EventDetectFailed = "detectFailed"
+	EventNoPublicIPv6 = "noPublicIPv6"
+	EventUpdateFailed = "updateFailed"
+	EventAuthFailed   = "authFailed"
+	EventTest         = "test"
+)
+
+// defaultTemplates 未配置模板时使用的标题与正文
+var defaultTemplates = map[string]config.MessageTemplate{
+	EventChange: {
+		Title: "{{.Family}} 地址已更新",
+		Body:  "{{.Family}} 地址已变更: {{or .OldIP \"未知\"}} -> {{.IP}}\n更新记录: {{join .Records \", \"}}",
+	},
+	EventRecovered: {
+		Title: "DDNS 已恢复正常",
+		Body:  "连续 {{.Count}} 次错误后已恢复正常",
+	},
+	EventConnectivity: {
+		Title: "IPv6 DDNS 更新失败",
+		Body:  "无法连接到公共 IPv6 地址",
+	},
+	EventDetectFailed: {
+		Title: "{{.Family}} DDNS 更新失败",
+		Body:  "获取{{.Family}}地址失败: {{.Error}}",
+	},
+	EventNoPublicIPv6: {
+		Title: "{{.Family}} DDNS 更新失败",
+		Body:  "没有可用的公网 IPv6 地址，检测到的地址均不可路由，未更新DNS记录: {{.Error}}",
+	},
+	EventUpdateFailed: {
+		Title: "{{.Family}} DDNS 更新失败",
+		Body:  "更新DNS记录失败: {{.Error}}",
+	},
+	EventAuthFailed: {
+		Title: "DNS 服务商凭证无效",
+		Body:  "{{.Provider}} 拒绝了配置的凭证（密钥无效、已轮换或权限不足），更新将持续失败，请检查 {{.Provider}} 配置。\n{{.Error}}",
+	},
+	EventTest: {
+		Title: "DDNS 测试通知",
+		Body:  "这是一条来自 {{.Host}} 的测试通知，收到说明通知配置正确",
+	},
+}
+
+// TemplateData 通知模板中可用的字段
+type TemplateData struct {
+	// Target 更新目标名称，未配置 targets 时为空
+	Target string
+	// Family 地址类型: IPv6 或 IPv4
+	Family string
+	// Type 记录类型: AAAA 或 A
+	Type string
+	// IP/OldIP 本次事件涉及的地址与变更前的地址，可能为空
+	IP    string
+	OldIP string
+	// Records 相关的域名记录
+	Records []string
+	// Error 故障原因
+	Error string
+	// Provider DNS 服务商名称
+	Provider string
+	// Count 连续错误次数，仅恢复通知使用
+	Count int
+	// Host 运行本程序的主机名
+	Host string
+	Time time.Time
+}
+
+// Templates 按事件渲染通知标题与正文的模板集合
+type Templates struct {
+	titles map[string]*template.Template
+	bodies map[string]*template.Template
+}
+
+var templateFuncs = template.FuncMap{"join": strings.Join}
+
+// NewTemplates 解析配置的模板，未配置的标题或正文使用默认文案
+func NewTemplates(cfg config.NotificationTemplates) (*Templates, error) {
+	configured := map[string]config.MessageTemplate{
+		EventChange:       cfg.Change,
+		EventRecovered:    cfg.Recovered,
+		EventConnectivity: cfg.Connectivity,
+		EventDetectFailed: cfg.DetectFailed,
+		EventNoPublicIPv6: cfg.NoPublicIPv6,
+		EventUpdateFailed: cfg.UpdateFailed,
+		EventAuthFailed:   cfg.AuthFailed,
+		EventTest:         cfg.Test,
+	}
+
+	t := &Templates{titles: make(map[string]*template.Template), bodies: make(map[string]*template.Template)}
+	for event, def := range defaultTemplates {
+		title, body := def.Title, def.Body
+		if custom := configured[event]; custom.Title != "" {
+			title = custom.Title
+		}
+		if custom := configured[event]; custom.Body != "" {
+			body = custom.Body
+		}
+		var err error
+		if t.titles[event], err = template.New(event + ".title").Funcs(templateFuncs).Parse(title); err != nil {
+			return nil, fmt.Errorf("parse notifications.templates.%s.title: %v", event, err)
+		}
+		if t.bodies[event], err = template.New(event + ".body").Funcs(templateFuncs).Parse(body); err != nil {
+			return nil, fmt.Errorf("parse notifications.templates.%s.body: %v", event, err)
+		}
+	}
+	return t, nil
+}
+
+// Render 渲染事件的标题与正文，Host 与 Time 为空时自动填充；渲染出错时改用默认文案
+func (t *Templates) Render(event string, data TemplateData) (title, body string) {
+	if data.Host == "" {
+		data.Host, _ = os.Hostname()
+	}
+	if data.Time.IsZero() {
+		data.Time = time.Now()
+	}
+	title, err := execute(t.titles[event], data)
+	if err == nil {
+		body, err = execute(t.bodies[event], data)
+	}
+	if err != nil {
+		logrus.Warnf("Failed to render %s notification template, using the default: %v", event, err)
+		def := defaultTemplates[event]
+		title, _ = execute(template.Must(template.New("").Funcs(templateFuncs).Parse(def.Title)), data)
+		body, _ = execute(template.Must(template.New("").Funcs(templateFuncs).Parse(def.Body)), data)
+	}
+	return title, body
+}
+
+func execute(tmpl *template.Template, data TemplateData) (string, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
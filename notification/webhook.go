@@ -0,0 +1,104 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
+)
+
+// defaultWebhookTemplate 未配置模板时使用的 JSON 请求体
+const defaultWebhookTemplate = `{"title": {{json .Title}}, "body": {{json .Body}}, "ip": {{json .IP}}, "hostname": {{json .Hostname}}, "timestamp": {{.Time.Unix}}}`
+
+// WebhookNotifier 将通知以 JSON 形式 POST 到指定地址
+type WebhookNotifier struct {
+	url     string
+	headers map[string]string
+	tmpl    *template.Template
+	client  *http.Client
+	// secret 为空时不签名
+	secret          []byte
+	signatureHeader string
+	timestampHeader string
+}
+
+// NewWebhookNotifier 创建 webhook 通知，模板中可用字段见 Message，json 函数用于输出转义后的 JSON 值
+func NewWebhookNotifier(cfg config.Webhook) (*WebhookNotifier, error) {
+	text := cfg.Template
+	if text == "" {
+		text = defaultWebhookTemplate
+	}
+
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse webhook template: %v", err)
+	}
+
+	return &WebhookNotifier{
+		url:             cfg.URL,
+		headers:         cfg.Headers,
+		tmpl:            tmpl,
+		client:          httpclient.New(10 * time.Second),
+		secret:          []byte(cfg.Secret),
+		signatureHeader: cfg.SignatureHeader,
+		timestampHeader: cfg.TimestampHeader,
+	}, nil
+}
+
+func (n *WebhookNotifier) Notify(msg Message) error {
+	var body bytes.Buffer
+	if err := n.tmpl.Execute(&body, msg); err != nil {
+		return fmt.Errorf("render webhook template: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(msg.context(), http.MethodPost, n.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range n.headers {
+		req.Header.Set(key, value)
+	}
+	if len(n.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(n.timestampHeader, timestamp)
+		req.Header.Set(n.signatureHeader, "sha256="+n.sign(timestamp, body.Bytes()))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+// sign 返回 HMAC-SHA256(secret, timestamp + "." + body) 的十六进制小写形式，
+// 时间戳参与签名，接收方可据此拒绝过期的重放请求
+func (n *WebhookNotifier) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, n.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,75 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/httpclient"
+)
+
+// wecomWebhookURL 企业微信群机器人的 webhook 地址，key 为机器人的密钥
+const wecomWebhookURL = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key="
+
+// WeComNotifier 通过企业微信群机器人发送通知
+type WeComNotifier struct {
+	webhookURL       string
+	msgType          string
+	mentionedMobiles []string
+	client           *http.Client
+}
+
+func NewWeComNotifier(cfg config.WeCom) *WeComNotifier {
+	webhookURL := cfg.WebhookURL
+	if webhookURL == "" {
+		webhookURL = wecomWebhookURL + url.QueryEscape(cfg.Key)
+	}
+	return &WeComNotifier{
+		webhookURL:       webhookURL,
+		msgType:          cfg.MsgType,
+		mentionedMobiles: cfg.MentionedMobiles,
+		client:           httpclient.New(10 * time.Second),
+	}
+}
+
+func (n *WeComNotifier) Notify(msg Message) error {
+	var payload map[string]any
+	if n.msgType == "markdown" {
+		payload = map[string]any{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"content": "**" + msg.Title + "**\n" + msg.Body},
+		}
+	} else {
+		text := map[string]any{"content": msg.Title + "\n\n" + msg.Body}
+		if len(n.mentionedMobiles) > 0 {
+			text["mentioned_mobile_list"] = n.mentionedMobiles
+		}
+		payload = map[string]any{"msgtype": "text", "text": text}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := send(n.client, msg, http.MethodPost, n.webhookURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode response (status %d): %v", resp.StatusCode, err)
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("%s (errcode %d)", result.ErrMsg, result.ErrCode)
+	}
+	return nil
+}
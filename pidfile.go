@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// writePidFile 将当前进程号写入 path；文件中记录的进程仍在运行时报错，残留的旧文件直接覆盖
+func writePidFile(path string) error {
+	if data, err := os.ReadFile(path); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid != os.Getpid() && processRunning(pid) {
+			return fmt.Errorf("process %d from %s is still running", pid, path)
+		}
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// removePidFile 删除由本进程写入的 PID 文件
+func removePidFile(path string) {
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		logrus.Warnf("PID file %s was taken over by another process, leaving it in place", path)
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		logrus.Errorf("Failed to remove PID file %s: %v", path, err)
+	}
+}
+
+// processRunning 通过信号 0 判断进程是否存在，不支持的平台视为不存在
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
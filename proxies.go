@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme/autocert"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/proxy"
+)
+
+// proxyServers 按 proxy 配置运行的反向代理，重新加载配置时以新配置替换
+type proxyServers struct {
+	mu      sync.Mutex
+	cfg     *config.Config
+	servers []*proxy.Server
+}
+
+// startProxies 按配置启动 HTTP 与 HTTPS 反向代理
+func startProxies(cfg *config.Config) (*proxyServers, error) {
+	servers, err := startProxyServers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyServers{cfg: cfg, servers: servers}, nil
+}
+
+// startProxyServers 启动配置中启用的反向代理，任一启动失败时关闭已启动的并返回错误
+func startProxyServers(cfg *config.Config) ([]*proxy.Server, error) {
+	access, err := proxy.NewAccessList(cfg.Proxy.AllowCIDRs, cfg.Proxy.DenyCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy access list: %w", err)
+	}
+	opts := proxy.Options{
+		AddForwardedHeaders: cfg.Proxy.AddForwardedHeaders,
+		Gzip:                cfg.Proxy.EnableGzip,
+		Access:              access,
+		MaxConnections:      cfg.Proxy.MaxConnections,
+		ReadTimeout:         time.Duration(cfg.Proxy.ReadTimeout) * time.Second,
+		WriteTimeout:        time.Duration(cfg.Proxy.WriteTimeout) * time.Second,
+		IdleTimeout:         time.Duration(cfg.Proxy.IdleTimeout) * time.Second,
+	}
+
+	var servers []*proxy.Server
+	// 判断是否需要启动 HTTP 反向代理
+	if cfg.Proxy.EnableHTTP {
+		server, err := proxy.StartReverseProxy(cfg.Proxy.HTTPListenAddr, cfg.Proxy.HTTPTargetAddr, opts)
+		if err != nil {
+			return nil, fmt.Errorf("start HTTP reverse proxy on %s: %w", cfg.Proxy.HTTPListenAddr, err)
+		}
+		servers = append(servers, server)
+	}
+
+	// 判断是否需要启动 HTTPS 反向代理
+	if cfg.Proxy.EnableHTTPS {
+		var certManager *autocert.Manager
+		if cfg.Proxy.ACMEEnabled {
+			certManager = proxy.NewCertManager(cfg.Proxy.ACMEDomains, cfg.Proxy.ACMECacheDir, cfg.Proxy.ACMEEmail)
+		}
+		server, err := proxy.StartReverseProxyTLS(cfg.Proxy.HTTPSListenAddr, cfg.Proxy.HTTPSTargetAddr, cfg.Proxy.CertFile, cfg.Proxy.KeyFile, certManager, opts)
+		if err != nil {
+			for _, s := range servers {
+				s.Close()
+			}
+			return nil, fmt.Errorf("start HTTPS reverse proxy on %s: %w", cfg.Proxy.HTTPSListenAddr, err)
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// reload 代理配置变化时先停止旧代理接受新连接，再以新配置启动；旧代理在 proxy.drainTimeout 内
+// 处理完已有请求后退出，超时后关闭剩余连接。新代理启动失败时以旧配置重新启动，返回代理配置是否已更新
+func (p *proxyServers) reload(cfg *config.Config) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if reflect.DeepEqual(p.cfg.Proxy, cfg.Proxy) {
+		return false, nil
+	}
+
+	timeout := time.Duration(p.cfg.Proxy.DrainTimeout) * time.Second
+	for _, server := range p.servers {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		done := server.Drain(ctx)
+		logrus.Printf("Draining reverse proxy on %s for up to %s", server.Addr, timeout)
+		go func() {
+			defer cancel()
+			if err := <-done; errors.Is(err, context.DeadlineExceeded) {
+				logrus.Warnf("Reverse proxy on %s did not drain within %s, closed the remaining connections", server.Addr, timeout)
+			} else if err != nil {
+				logrus.Errorf("Failed to drain reverse proxy on %s: %v", server.Addr, err)
+			} else {
+				logrus.Printf("Reverse proxy on %s drained", server.Addr)
+			}
+		}()
+	}
+
+	servers, err := startProxyServers(cfg)
+	if err != nil {
+		logrus.Errorf("Failed to start reverse proxy with the new config, restoring the previous one: %v", err)
+		var restoreErr error
+		if p.servers, restoreErr = startProxyServers(p.cfg); restoreErr != nil {
+			logrus.Errorf("Failed to restore reverse proxy: %v", restoreErr)
+		}
+		return false, fmt.Errorf("reload proxy: %w", err)
+	}
+	p.cfg = cfg
+	p.servers = servers
+	return true, nil
+}
+
+// shutdown 关闭当前的反向代理，等待已有请求处理完毕直到 ctx 结束
+func (p *proxyServers) shutdown(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, server := range p.servers {
+		if err := server.Shutdown(ctx); err != nil {
+			logrus.Errorf("Failed to shut down server on %s: %v", server.Addr, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// AccessList 按客户端地址控制是否允许访问后端，拒绝列表优先于允许列表
+type AccessList struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// NewAccessList 解析允许与拒绝的 CIDR 列表，支持 IPv4 与 IPv6，单个地址视为 /32 或 /128。
+// 允许列表为空时除拒绝列表外的地址均可访问；两个列表都为空时返回 nil，表示不做限制。
+func NewAccessList(allow, deny []string) (*AccessList, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	a := &AccessList{}
+	var err error
+	if a.allow, err = parsePrefixes(allow); err != nil {
+		return nil, err
+	}
+	if a.deny, err = parsePrefixes(deny); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// parsePrefix 解析 CIDR 或单个地址
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		prefix, err := parsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", value, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// Allowed 判断 remoteAddr(host:port 形式)是否允许访问，地址无法解析时拒绝
+func (a *AccessList) Allowed(remoteAddr string) bool {
+	if a == nil {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	// IPv4 客户端经双栈监听时表现为 ::ffff:a.b.c.d，按 IPv4 匹配
+	addr = addr.Unmap().WithZone("")
+
+	if containsAddr(a.deny, addr) {
+		return false
+	}
+	return len(a.allow) == 0 || containsAddr(a.allow, addr)
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+package proxy
+
+import (
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// NewCertManager 创建自动申请与续期 Let's Encrypt 证书的管理器，只为 domains 中的域名签发证书。
+// 证书通过 TLS-ALPN-01 方式验证，HTTPS 监听端口需能从公网以 443 访问。
+func NewCertManager(domains []string, cacheDir, email string) *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      email,
+	}
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// failureCooldown 后端连接失败后暂停转发的时间
+const failureCooldown = 30 * time.Second
+
+// upstream 单个后端及其最近的失败时间
+type upstream struct {
+	target *url.URL
+	proxy  *httputil.ReverseProxy
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+func (u *upstream) available(now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !now.Before(u.downUntil)
+}
+
+func (u *upstream) markDown() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.downUntil = time.Now().Add(failureCooldown)
+}
+
+// balancer 按轮询方式在多个后端之间分发请求，跳过最近连接失败的后端
+type balancer struct {
+	upstreams []*upstream
+	next      atomic.Uint64
+}
+
+// newBalancer 为每个后端地址创建转发器，地址无法解析时退出；gzip 为 true 时压缩适合压缩的响应
+func newBalancer(targetAddrs []string, addForwardedHeaders, gzip bool) *balancer {
+	b := &balancer{}
+	for _, addr := range targetAddrs {
+		target, err := url.Parse(addr)
+		if err != nil {
+			log.Fatalf("Failed to parse target address: %v", err)
+		}
+
+		u := &upstream{target: target, proxy: httputil.NewSingleHostReverseProxy(target)}
+		u.proxy.Transport = transport
+		director := u.proxy.Director
+		u.proxy.Director = func(req *http.Request) {
+			director(req)
+			setForwardedHeaders(req, addForwardedHeaders)
+		}
+		if gzip {
+			u.proxy.ModifyResponse = gzipResponse
+		}
+		u.proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			// 客户端主动断开不算后端故障
+			if !errors.Is(err, context.Canceled) {
+				log.Printf("Upstream %s failed, skipping it for %s: %v", u.target, failureCooldown, err)
+				u.markDown()
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		b.upstreams = append(b.upstreams, u)
+	}
+	return b
+}
+
+// pick 选择下一个可用后端，全部不可用时仍按轮询顺序返回，避免完全拒绝服务
+func (b *balancer) pick() *upstream {
+	n := uint64(len(b.upstreams))
+	start := b.next.Add(1) - 1
+	now := time.Now()
+	for i := uint64(0); i < n; i++ {
+		u := b.upstreams[(start+i)%n]
+		if u.available(now) {
+			return u
+		}
+	}
+	return b.upstreams[start%n]
+}
+
+func (b *balancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.pick().proxy.ServeHTTP(w, r)
+}
+
+// setForwardedHeaders 设置或移除向后端传递客户端地址与协议的请求头
+func setForwardedHeaders(req *http.Request, enabled bool) {
+	if !enabled {
+		// 值为 nil 时 ReverseProxy 不再追加 X-Forwarded-For
+		req.Header["X-Forwarded-For"] = nil
+		return
+	}
+	// X-Forwarded-For 由 ReverseProxy 在 Director 之后追加客户端地址
+	if ip, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		req.Header.Set("X-Real-IP", ip)
+	}
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	req.Header.Set("X-Forwarded-Proto", proto)
+}
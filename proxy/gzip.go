@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gzipMinLength 已知长度小于该值的响应不压缩，压缩收益抵不过额外开销
+const gzipMinLength = 1024
+
+// compressedTypes 本身已压缩或需要逐条推送的内容类型，不再压缩
+var compressedTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-bzip2",
+	"application/x-xz", "application/zstd", "application/x-7z-compressed", "application/x-rar-compressed",
+	"application/octet-stream", "application/pdf", "application/wasm",
+	"text/event-stream",
+}
+
+// gzipResponse 客户端接受 gzip 且后端响应适合压缩时，将响应体改为边读边压缩的 gzip 流，
+// 用作 ReverseProxy.ModifyResponse
+func gzipResponse(resp *http.Response) error {
+	if !shouldGzip(resp) {
+		return nil
+	}
+	resp.Header.Add("Vary", "Accept-Encoding")
+	resp.Header.Set("Content-Encoding", "gzip")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	// 压缩后内容不同，强校验 ETag 改为弱校验
+	if etag := resp.Header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		resp.Header.Set("ETag", "W/"+etag)
+	}
+
+	pr, pw := io.Pipe()
+	src := resp.Body
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, src)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	resp.Body = &gzipBody{PipeReader: pr, src: src}
+	return nil
+}
+
+// gzipBody 压缩后的响应体，关闭时同时关闭后端响应体以结束压缩
+type gzipBody struct {
+	*io.PipeReader
+	src io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	b.PipeReader.Close()
+	return b.src.Close()
+}
+
+// shouldGzip 判断响应是否需要压缩
+func shouldGzip(resp *http.Response) bool {
+	if resp.Request == nil || resp.Request.Method == http.MethodHead || !acceptsGzip(resp.Request.Header) {
+		return false
+	}
+	// 无响应体、升级连接与分段响应不压缩
+	switch resp.StatusCode {
+	case http.StatusSwitchingProtocols, http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	if resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Content-Range") != "" {
+		return false
+	}
+	if strings.Contains(strings.ToLower(resp.Header.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+	if resp.ContentLength >= 0 && resp.ContentLength < gzipMinLength {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	if mediaType == "image/svg+xml" {
+		return true
+	}
+	for _, prefix := range compressedTypes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptsGzip 按 Accept-Encoding 判断客户端是否接受 gzip，q=0 视为拒绝
+func acceptsGzip(header http.Header) bool {
+	for _, value := range header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "gzip" && coding != "*" {
+				continue
+			}
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// overloadResponse 连接数超出上限时返回给 HTTP 客户端的响应
+const overloadResponse = "HTTP/1.1 503 Service Unavailable\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Connection: close\r\n" +
+	"Content-Length: 20\r\n" +
+	"\r\n" +
+	"Too many connections"
+
+// ConnectionStats 一个代理监听地址的连接数
+type ConnectionStats struct {
+	Listen string `json:"listen"`
+	Active int64  `json:"active"`
+	// Max 连接数上限，0 表示不限制
+	Max int `json:"max,omitempty"`
+}
+
+var (
+	listenersMu sync.Mutex
+	listeners   []*limitListener
+)
+
+// Connections returns the current connection count of every running proxy listener.
+func Connections() []ConnectionStats {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	stats := make([]ConnectionStats, len(listeners))
+	for i, l := range listeners {
+		stats[i] = ConnectionStats{Listen: l.Addr().String(), Active: l.active.Load(), Max: l.max}
+	}
+	return stats
+}
+
+// limitListener 统计活动连接数，超过 max 时直接拒绝新连接；max 为 0 时只统计不限制。
+// 明文 HTTP 连接在关闭前返回 503，TLS 连接无法在握手前应答，直接关闭
+type limitListener struct {
+	net.Listener
+	max    int
+	plain  bool
+	active atomic.Int64
+	// closed 在监听关闭后关闭
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newLimitListener 包装 ln 并登记到 Connections，关闭时注销
+func newLimitListener(ln net.Listener, max int, plain bool) *limitListener {
+	l := &limitListener{Listener: ln, max: max, plain: plain, closed: make(chan struct{})}
+	listenersMu.Lock()
+	listeners = append(listeners, l)
+	listenersMu.Unlock()
+	return l
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if active := l.active.Add(1); l.max > 0 && active > int64(l.max) {
+			l.active.Add(-1)
+			log.Printf("Rejected connection from %s: %d connections already open", conn.RemoteAddr(), l.max)
+			l.reject(conn)
+			continue
+		}
+		return &countedConn{Conn: conn, listener: l}, nil
+	}
+}
+
+// reject 关闭超出上限的连接，不阻塞 Accept
+func (l *limitListener) reject(conn net.Conn) {
+	if !l.plain {
+		conn.Close()
+		return
+	}
+	go func() {
+		defer conn.Close()
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		conn.Write([]byte(overloadResponse))
+	}()
+}
+
+func (l *limitListener) Close() error {
+	listenersMu.Lock()
+	for i, other := range listeners {
+		if other == l {
+			listeners = append(listeners[:i], listeners[i+1:]...)
+			break
+		}
+	}
+	listenersMu.Unlock()
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() { close(l.closed) })
+	return err
+}
+
+// countedConn 关闭时归还连接计数，重复关闭只计一次
+type countedConn struct {
+	net.Conn
+	listener *limitListener
+	once     sync.Once
+}
+
+func (c *countedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() { c.listener.active.Add(-1) })
+	return err
+}
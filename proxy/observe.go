@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"ddns-ipv6/metrics"
+)
+
+// statusRecorder 记录写出的状态码，Unwrap 使 ResponseController 仍能 Flush 与接管连接
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	// 103 等信息性响应之后还会写出最终状态码
+	if r.status == 0 && status >= http.StatusOK {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// observe 记录每个请求的方法、路径、状态码与耗时，写入 debug 日志与 Prometheus 指标
+func observe(listenAddr string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		switch {
+		case rec.status != 0:
+		case upgradeType(r) != "":
+			// 升级成功后 ReverseProxy 直接在接管的连接上写出 101，不经过 WriteHeader
+			rec.status = http.StatusSwitchingProtocols
+		default:
+			rec.status = http.StatusOK
+		}
+		duration := time.Since(start)
+		logrus.WithFields(logrus.Fields{
+			"listen":   listenAddr,
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"status":   rec.status,
+			"duration": duration,
+		}).Debug("Proxy request completed")
+		metrics.ObserveProxyRequest(listenAddr, metricMethod(r.Method), rec.status, duration)
+	})
+}
+
+// metricMethod 将非标准的请求方法归为 OTHER，避免客户端随意构造方法导致指标标签无限增长
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
@@ -1,11 +1,18 @@
 package proxy
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
+	"strings"
 	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 var transport = &http.Transport{
@@ -14,46 +21,152 @@ var transport = &http.Transport{
 	DisableKeepAlives: false,
 }
 
-// StartReverseProxy starts a reverse proxy server
-func StartReverseProxy(listenAddr string, targetAddr string) {
-	target, err := url.Parse(targetAddr)
-	if err != nil {
-		log.Fatalf("Failed to parse target address: %v", err)
+// Options 反向代理的转发与连接设置
+type Options struct {
+	// AddForwardedHeaders 向后端传递客户端地址与协议
+	AddForwardedHeaders bool
+	// Gzip 客户端接受 gzip 时压缩后端未压缩的响应
+	Gzip bool
+	// Access 不允许的客户端在转发前返回 403，为 nil 时不限制
+	Access *AccessList
+	// MaxConnections 同时打开的客户端连接数上限，0 表示不限制
+	MaxConnections int
+	// ReadTimeout、WriteTimeout、IdleTimeout 同 http.Server 的对应字段，0 表示不限制
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+// Server is a running reverse proxy.
+type Server struct {
+	*http.Server
+	listener *limitListener
+}
+
+// Drain stops accepting new connections and returns once the listener is closed,
+// so another server can bind the same address. Requests in flight keep being served
+// in the background until they finish or ctx is done, after which the remaining
+// connections are closed. The returned channel receives nil when draining completed
+// and ctx.Err() when it timed out. Upgraded connections such as WebSocket are not
+// tracked and stay open.
+func (s *Server) Drain(ctx context.Context) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		err := s.Shutdown(ctx)
+		if err != nil {
+			s.Close()
+		}
+		done <- err
+	}()
+	<-s.listener.closed
+	return done
+}
+
+// newServer 创建带超时设置的代理服务器，防止慢速客户端长期占用连接
+func newServer(listenAddr string, targetAddrs []string, opts Options) *http.Server {
+	return &http.Server{
+		Addr:         listenAddr,
+		Handler:      observe(listenAddr, newHandler(targetAddrs, opts)),
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		IdleTimeout:  opts.IdleTimeout,
 	}
+}
 
-	proxy := httputil.NewSingleHostReverseProxy(target)
-	proxy.Transport = transport
+// newHandler 创建在 targetAddrs 之间轮询转发的处理器
+func newHandler(targetAddrs []string, opts Options) *http.ServeMux {
+	b := newBalancer(targetAddrs, opts.AddForwardedHeaders, opts.Gzip)
+	access := opts.Access
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("Proxying request for: %s", r.URL.Path)
-		proxy.ServeHTTP(w, r)
+		if !access.Allowed(r.RemoteAddr) {
+			logrus.Debugf("Rejected proxy request from %s for %s", r.RemoteAddr, r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		// 升级请求(如 WebSocket)由 ReverseProxy 在后端返回 101 后接管连接并双向转发
+		if upgrade := upgradeType(r); upgrade != "" {
+			logrus.Debugf("Proxying %s upgrade for: %s", upgrade, r.URL.Path)
+			// 升级后的连接长期存在，清除服务器设置的读写超时，由双方自行维持
+			rc := http.NewResponseController(w)
+			rc.SetReadDeadline(time.Time{})
+			rc.SetWriteDeadline(time.Time{})
+		} else {
+			logrus.Debugf("Proxying request for: %s", r.URL.Path)
+		}
+		b.ServeHTTP(w, r)
 	})
+	return mux
+}
 
-	log.Printf("Starting reverse proxy on %s, forwarding to %s", listenAddr, targetAddr)
-	if err := http.ListenAndServe(listenAddr, mux); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// upgradeType 返回请求要升级到的协议，非升级请求返回空字符串
+func upgradeType(r *http.Request) string {
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return strings.ToLower(r.Header.Get("Upgrade"))
+			}
+		}
 	}
+	return ""
 }
 
-// StartReverseProxyTLS starts a reverse proxy server with TLS
-func StartReverseProxyTLS(listenAddr, targetAddr, certFile, keyFile string) {
-	target, err := url.Parse(targetAddr)
+// StartReverseProxy starts a reverse proxy server in the background.
+// Connection upgrades such as WebSocket are forwarded and piped in both directions.
+// The listener is bound before returning, so address conflicts are reported as an error.
+// Requests from clients rejected by opts.Access get 403, and connections beyond
+// opts.MaxConnections get 503 and are closed.
+// With opts.Gzip uncompressed responses are gzipped for clients that accept it.
+// Every request is logged at debug level and recorded in the ddns_proxy_* metrics.
+// The returned server can be stopped with Shutdown or replaced gracefully with Drain.
+func StartReverseProxy(listenAddr string, targetAddrs []string, opts Options) (*Server, error) {
+	server := newServer(listenAddr, targetAddrs, opts)
+	l, err := net.Listen("tcp", listenAddr)
 	if err != nil {
-		log.Fatalf("Failed to parse target address: %v", err)
+		return nil, err
 	}
+	ln := newLimitListener(l, opts.MaxConnections, true)
 
-	proxy := httputil.NewSingleHostReverseProxy(target)
-	proxy.Transport = transport
-
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("Proxying request for: %s", r.URL.Path)
-		proxy.ServeHTTP(w, r)
-	})
+	log.Printf("Starting reverse proxy on %s, forwarding to %s", listenAddr, strings.Join(targetAddrs, ", "))
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Reverse proxy on %s stopped: %v", listenAddr, err)
+		}
+	}()
+	return &Server{Server: server, listener: ln}, nil
+}
 
-	log.Printf("Starting TLS reverse proxy on %s, forwarding to %s", listenAddr, targetAddr)
-	if err := http.ListenAndServeTLS(listenAddr, certFile, keyFile, mux); err != nil {
-		log.Fatalf("Failed to start TLS server: %v", err)
+// StartReverseProxyTLS starts a reverse proxy server with TLS in the background.
+// When certManager is not nil certificates are obtained from it and certFile/keyFile are ignored.
+// Certificate loading and binding happen before returning, so their failures are reported as an error.
+// Requests from clients rejected by opts.Access get 403, and connections beyond
+// opts.MaxConnections are closed before the TLS handshake.
+// With opts.Gzip uncompressed responses are gzipped for clients that accept it.
+// Every request is logged at debug level and recorded in the ddns_proxy_* metrics.
+// The returned server can be stopped with Shutdown or replaced gracefully with Drain.
+func StartReverseProxyTLS(listenAddr string, targetAddrs []string, certFile, keyFile string, certManager *autocert.Manager, opts Options) (*Server, error) {
+	server := newServer(listenAddr, targetAddrs, opts)
+	if certManager != nil {
+		server.TLSConfig = certManager.TLSConfig()
+	} else {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load certificate: %w", err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	l, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
 	}
+	ln := newLimitListener(l, opts.MaxConnections, false)
+
+	log.Printf("Starting TLS reverse proxy on %s, forwarding to %s", listenAddr, strings.Join(targetAddrs, ", "))
+	go func() {
+		if err := server.ServeTLS(ln, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("TLS reverse proxy on %s stopped: %v", listenAddr, err)
+		}
+	}()
+	return &Server{Server: server, listener: ln}, nil
 }
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartReverseProxyAddressInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	server, err := StartReverseProxy(ln.Addr().String(), []string{"http://127.0.0.1:1"}, Options{})
+	if err == nil {
+		server.Close()
+		t.Fatalf("StartReverseProxy(%s) succeeded on an address already in use", ln.Addr())
+	}
+}
+
+// upgradeEcho 模拟 WebSocket 后端：返回 101 后先发送一行问候，再逐行回显客户端发送的内容
+func upgradeEcho(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("backend hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(brw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\nwelcome\n")
+		brw.Flush()
+		for {
+			line, err := brw.ReadString('\n')
+			if err != nil {
+				return
+			}
+			brw.WriteString("echo " + line)
+			brw.Flush()
+		}
+	})
+}
+
+func TestUpgradeRoundTrip(t *testing.T) {
+	backend := httptest.NewServer(upgradeEcho(t))
+	defer backend.Close()
+	// 经过与 newServer 相同的 observe 与 gzip 包装，确认 Hijack 仍能拿到真实连接
+	front := httptest.NewServer(observe("test", newHandler([]string{backend.URL}, Options{Gzip: true})))
+	defer front.Close()
+
+	conn, err := net.Dial("tcp", front.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest(http.MethodGet, front.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Accept-Encoding", "gzip")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+
+	// 后端到客户端
+	if line, err := br.ReadString('\n'); err != nil || line != "welcome\n" {
+		t.Fatalf("greeting = %q, %v", line, err)
+	}
+	// 客户端到后端再返回
+	for _, msg := range []string{"hello\n", "second frame\n"} {
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		if line, err := br.ReadString('\n'); err != nil || line != "echo "+msg {
+			t.Fatalf("echo of %q = %q, %v", msg, line, err)
+		}
+	}
+}
@@ -0,0 +1,915 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/dns"
+	"ddns-ipv6/health"
+	"ddns-ipv6/httpclient"
+	"ddns-ipv6/iputil"
+	"ddns-ipv6/metrics"
+	"ddns-ipv6/notification"
+)
+
+// ipFamily 描述一种地址类型及其检测方式
+type ipFamily struct {
+	name       string
+	recordType string
+	// detect 返回检测到的地址与得到该地址的检测方式
+	detect func(ctx context.Context, cfg config.Network) (string, string, error)
+}
+
+var (
+	familyIPv6 = ipFamily{name: "IPv6", recordType: "AAAA", detect: detectIPv6}
+	familyIPv4 = ipFamily{name: "IPv4", recordType: "A", detect: detectIPv4}
+)
+
+// detectIPv6 检测本地 IPv6 地址，开启 AllAddresses 时返回逗号分隔的全部地址，
+// 地址已排序，缓存比较的是整个地址集合。
+// 任一地址不可发布(如检测回退到链路本地地址)时返回错误，拒绝更新 DNS；
+// 检测链中的某个方式得到不可发布的地址时改用下一个方式
+func detectIPv6(ctx context.Context, cfg config.Network) (string, string, error) {
+	if !cfg.AllAddresses {
+		return iputil.GetLocalIPv6(ctx, cfg)
+	}
+
+	ips, err := iputil.GetLocalIPv6Addresses(cfg)
+	if err != nil {
+		return "", "interface", err
+	}
+	for _, ip := range ips {
+		if err := iputil.CheckPublishable(ip, cfg.AddressScope); err != nil {
+			return "", "interface", fmt.Errorf("refusing to publish detected address: %w", err)
+		}
+	}
+	return strings.Join(ips, ","), "interface", nil
+}
+
+// detectIPv4 从本机网卡检测 IPv4 地址
+func detectIPv4(ctx context.Context, cfg config.Network) (string, string, error) {
+	ip, err := iputil.GetLocalIPv4(ctx, cfg)
+	return ip, "interface", err
+}
+
+// templateData 返回该地址类型的通知模板数据，err 为故障原因，可为 nil
+func (f ipFamily) templateData(err error) notification.TemplateData {
+	data := notification.TemplateData{Family: f.name, Type: f.recordType}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	return data
+}
+
+// detectionMethods 返回该地址类型依次尝试的检测方式，用于输出
+func (f ipFamily) detectionMethods(cfg config.Network) string {
+	if f.recordType == "AAAA" {
+		return strings.Join(cfg.DetectionChain(), ", ")
+	}
+	return "interface"
+}
+
+// enabledFamilies 返回配置中启用的地址类型
+func enabledFamilies(cfg *config.Config) []ipFamily {
+	var families []ipFamily
+	if cfg.EnableIPv6 {
+		families = append(families, familyIPv6)
+	}
+	if cfg.EnableIPv4 {
+		families = append(families, familyIPv4)
+	}
+	return families
+}
+
+// updater 负责检测本地地址并按需更新 DNS 记录
+type updater struct {
+	// mu 保证检测更新与配置重载互斥，cfg/provider/fallback/notifier 只在持有 mu 时读写
+	mu sync.Mutex
+
+	cfg      *config.Config
+	provider dns.Provider
+	// fallback 备用服务商，未配置 dns.fallback 时为 nil
+	fallback    dns.Provider
+	cache       *dns.DNSCache
+	healthCheck *health.HealthCheck
+	notifier    *notification.AsyncNotifier
+	templates   *notification.Templates
+
+	// updated 记录各记录类型启动后是否已成功更新过
+	updated map[string]bool
+	// adaptiveInterval 自适应模式下当前的检查间隔
+	adaptiveInterval time.Duration
+	// lastFailureNotify 各类故障最近一次发送通知的时间，用于通知冷却
+	lastFailureNotify map[string]time.Time
+	// candidates 各记录类型尚未达到 StabilityChecks 的新地址
+	candidates map[string]candidate
+	// requestID 当前检测周期的请求ID，随日志、出站请求与通知传递
+	requestID string
+	// deferredUntil 最近一轮因静默时段推迟了更新时为时段结束时间，下次检查不晚于该时间；未推迟时为零值
+	deferredUntil time.Time
+	// connectivityChecked 已在首次 IPv6 检测时判断过连通性
+	connectivityChecked bool
+	// stalePrimary 各记录类型中只由备用服务商更新、主服务商仍未同步的子域名
+	stalePrimary map[string][]string
+	// ptrPublished 已发布 PTR 记录的 AAAA 地址(集合)
+	ptrPublished string
+}
+
+// candidate 与已确认地址不同、等待连续检测确认的新地址
+type candidate struct {
+	ip    string
+	count int
+}
+
+// newUpdater 为一个更新目标创建独立的缓存、健康状态、DNS 服务商与通知器
+func newUpdater(cfg *config.Config) (*updater, error) {
+	cache := dns.NewDNSCache(cfg.CacheFile)
+
+	logrus.Println("Creating DNS provider client...")
+	provider, err := dns.NewProvider(*cfg, cache)
+	if err != nil {
+		return nil, fmt.Errorf("create DNS provider: %w", err)
+	}
+	logrus.Println("DNS provider client created successfully.")
+	fallback, err := newFallbackProvider(cfg, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create notifier: %w", err)
+	}
+	templates, err := notification.NewTemplates(cfg.Notifications.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	// 重启后从缓存恢复当前发布的地址，不必等到下一次变更
+	for _, family := range enabledFamilies(cfg) {
+		if ip, _ := cache.GetIP(family.recordType); ip != "" {
+			metrics.ObservePublishedIP(cfg.Name, family.recordType, ip)
+		}
+	}
+
+	healthCheck := health.NewHealthCheck()
+	healthCheck.SetErrorThreshold(cfg.Health.ErrorThreshold)
+
+	return &updater{
+		cfg:               cfg,
+		provider:          provider,
+		fallback:          fallback,
+		cache:             cache,
+		healthCheck:       healthCheck,
+		notifier:          notifier,
+		templates:         templates,
+		updated:           make(map[string]bool),
+		lastFailureNotify: make(map[string]time.Time),
+		candidates:        make(map[string]candidate),
+		stalePrimary:      make(map[string][]string),
+	}, nil
+}
+
+// newFallbackProvider 创建 dns.fallback 配置的备用服务商，未配置时返回 nil
+func newFallbackProvider(cfg *config.Config, cache *dns.DNSCache) (dns.Provider, error) {
+	fallback := cfg.FallbackConfig()
+	if fallback == nil {
+		return nil, nil
+	}
+	provider, err := dns.NewProvider(*fallback, cache)
+	if err != nil {
+		return nil, fmt.Errorf("create fallback DNS provider: %w", err)
+	}
+	return provider, nil
+}
+
+// name 返回用于日志的目标名称
+func (u *updater) name() string {
+	return targetName(u.cfg)
+}
+
+// targetName 返回目标名称，未命名的单一目标使用域名
+func targetName(cfg *config.Config) string {
+	if cfg.Name == "" {
+		return cfg.Domain.Domain
+	}
+	return cfg.Name
+}
+
+// checkConnectivity 根据首次 IPv6 检测的结果判断连通性：检测到公网可路由地址时视为连通，不再拨号；
+// 检测失败或地址不可路由时拨号探测，区分是本机检测配置的问题还是根本没有 IPv6，无法连接时发送通知
+func (u *updater) checkConnectivity(log *logrus.Entry, ip string, detectErr error) {
+	if u.connectivityChecked {
+		return
+	}
+	u.connectivityChecked = true
+
+	if detectErr == nil {
+		if addr := net.ParseIP(strings.Split(ip, ",")[0]); addr.IsGlobalUnicast() && !addr.IsPrivate() {
+			log.Printf("IPv6 connectivity assumed from detected global address %s, skipping the dial check", addr)
+			return
+		}
+	}
+
+	cfg := u.cfg
+	timeout := time.Duration(cfg.Network.ConnectivityTimeout) * time.Second
+	source, err := iputil.CheckIPv6Connectivity(cfg.Network.ConnectivityHosts, timeout)
+	switch {
+	case err != nil:
+		log.WithError(err).Println("IPv6 connectivity check failed, sending notification...")
+		u.notifyFailure("connectivity", notification.EventConnectivity, notification.TemplateData{Family: "IPv6", Type: "AAAA"})
+	case detectErr != nil:
+		log.Printf("IPv6 connectivity confirmed via %s, the detection failure is likely caused by the network settings", source)
+	default:
+		log.Printf("Detected IPv6 address is not globally routable, connectivity confirmed via %s", source)
+	}
+}
+
+// reconcile 解析每条记录当前发布的值并据此设置缓存：所有记录都解析为同一地址时以该地址为准，
+// 记录之间不一致或不存在时清除缓存以便首次检测即更新；解析出错时保留原缓存
+func (u *updater) reconcile(ctx context.Context) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	cfg := u.cfg
+	for _, family := range enabledFamilies(cfg) {
+		entry := logrus.WithField("type", family.recordType)
+		if cfg.Name != "" {
+			entry = entry.WithField("target", cfg.Name)
+		}
+		published, err := u.publishedIP(ctx, family.recordType)
+		if err != nil {
+			entry.WithError(err).Warn("Failed to resolve published records, keeping cached address")
+			continue
+		}
+		cachedIP, _ := u.cache.GetIP(family.recordType)
+		if published != cachedIP {
+			entry.Printf("Published %s records resolve to %q, replacing cached address %q", family.recordType, published, cachedIP)
+		}
+		u.cache.SeedIP(family.recordType, published)
+	}
+}
+
+// removeRecords 删除本实例发布的记录(removeRecordOnExit)并清除缓存，使下次启动时重新发布；
+// 记录已被其他实例改为别的地址时保留该记录
+func (u *updater) removeRecords(ctx context.Context) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	cfg := u.cfg
+	if !cfg.RemoveRecordOnExit {
+		return
+	}
+	for _, family := range enabledFamilies(cfg) {
+		ip, _ := u.cache.GetIP(family.recordType)
+		if ip == "" {
+			continue
+		}
+		entry := logrus.WithFields(logrus.Fields{"type": family.recordType, strings.ToLower(family.name): ip})
+		if cfg.Name != "" {
+			entry = entry.WithField("target", cfg.Name)
+		}
+		if cfg.DryRun {
+			entry.Printf("[dry-run] Would remove %s records on exit", family.recordType)
+			continue
+		}
+
+		removed := true
+		for _, subDomain := range cfg.Domain.AllSubDomains() {
+			name := cfg.Domain.Hostname(subDomain)
+			err := dns.DeleteRecord(ctx, u.provider, *cfg, subDomain, family.recordType, ip)
+			switch {
+			case errors.Is(err, dns.ErrRecordChanged):
+				entry.Warnf("Leaving %s in place: %v", name, err)
+			case err != nil:
+				entry.WithError(err).Errorf("Failed to remove %s record %s", family.recordType, name)
+				removed = false
+			default:
+				entry.Printf("Removed %s record %s", family.recordType, name)
+			}
+		}
+		if removed {
+			u.cache.SeedIP(family.recordType, "")
+		}
+	}
+}
+
+// publishedIP 返回所有子域名共同发布的地址集合(排序后以逗号分隔，格式与检测结果一致)，
+// 记录不存在或彼此不一致时返回空字符串
+func (u *updater) publishedIP(ctx context.Context, recordType string) (string, error) {
+	published := ""
+	for i, name := range u.cfg.Domain.Hostnames() {
+		addrs, err := dns.ResolveRecord(ctx, name, recordType, u.cfg.Verify.Resolver)
+		if err != nil {
+			return "", err
+		}
+		value := strings.Join(iputil.SortAddresses(addrs), ",")
+		if value == "" || (i > 0 && value != published) {
+			return "", nil
+		}
+		published = value
+	}
+	return published, nil
+}
+
+// loop 按检查间隔定期检测并更新，直到 ctx 取消
+func (u *updater) loop(ctx context.Context) {
+	for {
+		changed, err := u.run(ctx)
+		interval := u.untilDeferred(u.nextInterval(changed, err))
+		logrus.Debugf("Next check for %s in %s", u.name(), interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// run 对每种启用的地址类型执行一次检测与更新，返回是否检测到地址变化及本轮出现的错误。
+// 整轮耗时受 CycleTimeout 限制，超时后未完成的检测与更新被取消并计为错误。
+func (u *updater) run(ctx context.Context) (bool, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	timeout := time.Duration(u.cfg.CycleTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	u.requestID = httpclient.NewRequestID()
+	defer func() { u.requestID = "" }()
+	u.deferredUntil = time.Time{}
+	ctx = httpclient.WithRequestID(ctx, u.requestID)
+
+	changed := false
+	var errs []error
+	for _, family := range enabledFamilies(u.cfg) {
+		familyChanged, err := u.runFamily(ctx, family)
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("cycle timed out after %s: %w", timeout, err)
+		}
+		changed = changed || familyChanged
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", family.name, err))
+		}
+	}
+
+	// 只有整轮都成功(包括地址未变化无需更新)才清零连续错误数，避免健康状态一直停留在失败
+	if len(errs) == 0 {
+		u.recordSuccess()
+	}
+	return changed, errors.Join(errs...)
+}
+
+// recordSuccess 清零连续错误数，从不健康状态恢复时发送一次恢复通知
+func (u *updater) recordSuccess() {
+	previous := u.healthCheck.RecordSuccess()
+	metrics.ObserveRecovered(u.cfg.Name)
+
+	if previous >= u.cfg.Health.ErrorThreshold {
+		logrus.Printf("Recovered after %d consecutive errors, sending notification...", previous)
+		u.notify(notification.EventRecovered, notification.TemplateData{Count: previous})
+		// 恢复后重置冷却，下一次故障立即通知
+		clear(u.lastFailureNotify)
+	}
+}
+
+// nextInterval 根据本轮结果计算下次检查前的等待时间。
+// 开启自适应间隔时，地址连续未变化则间隔翻倍直至 MaxInterval，检测到变化立即回到 MinInterval；
+// 出错时保持当前间隔不变。
+func (u *updater) nextInterval(changed bool, err error) time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	cfg := u.cfg
+	if !cfg.AdaptiveInterval {
+		return time.Duration(cfg.CheckInterval) * time.Second
+	}
+
+	minInterval := time.Duration(cfg.MinInterval) * time.Second
+	maxInterval := time.Duration(cfg.MaxInterval) * time.Second
+	switch {
+	case changed || u.adaptiveInterval == 0:
+		u.adaptiveInterval = minInterval
+	case err == nil:
+		u.adaptiveInterval *= 2
+	}
+	u.adaptiveInterval = max(minInterval, min(u.adaptiveInterval, maxInterval))
+	return u.adaptiveInterval
+}
+
+// untilDeferred 本轮有更新因静默时段推迟时，将等待时间缩短到静默时段结束，使推迟的更新尽快执行
+func (u *updater) untilDeferred(interval time.Duration) time.Duration {
+	until, ok := u.deferred()
+	if !ok {
+		return interval
+	}
+	return max(min(interval, time.Until(until)), time.Second)
+}
+
+// deferred 返回最近一轮是否因静默时段推迟了更新及推迟到的时间
+func (u *updater) deferred() (time.Time, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.deferredUntil, !u.deferredUntil.IsZero()
+}
+
+// reload 应用新的配置，检查间隔、域名、服务商、通知配置与健康检查的错误阈值立即生效，其余变更需重启
+func (u *updater) reload(cfg *config.Config) error {
+	provider, err := dns.NewProvider(*cfg, u.cache)
+	if err != nil {
+		return fmt.Errorf("create DNS provider: %w", err)
+	}
+	fallback, err := newFallbackProvider(cfg, u.cache)
+	if err != nil {
+		return err
+	}
+	templates, err := notification.NewTemplates(cfg.Notifications.Templates)
+	if err != nil {
+		return err
+	}
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		return fmt.Errorf("create notifier: %w", err)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, field := range config.RestartRequired(u.cfg, cfg) {
+		logrus.Warnf("Config change in %s requires a restart to take effect", field)
+	}
+	u.cfg = cfg
+	u.healthCheck.SetErrorThreshold(cfg.Health.ErrorThreshold)
+	u.provider = provider
+	u.fallback = fallback
+	// 旧通知器在后台发送完剩余通知后退出
+	go closeNotifier(u.notifier)
+	u.notifier = notifier
+	u.templates = templates
+	return nil
+}
+
+// notifyQueueSize 异步通知队列长度
+const notifyQueueSize = 16
+
+// newNotifier 创建异步发送的通知器，通知渠道缓慢时不阻塞更新流程
+func newNotifier(cfg *config.Config) (*notification.AsyncNotifier, error) {
+	notifier, err := notification.New(*cfg)
+	if err != nil {
+		return nil, err
+	}
+	return notification.NewAsyncNotifier(notifier, notifyQueueSize), nil
+}
+
+// closeNotifier 等待通知器发送完队列中的通知
+func closeNotifier(notifier *notification.AsyncNotifier) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := notifier.Close(ctx); err != nil {
+		logrus.Errorf("Failed to flush notifications: %v", err)
+	}
+}
+
+func (u *updater) runFamily(ctx context.Context, family ipFamily) (bool, error) {
+	cfg := u.cfg
+	ipField := strings.ToLower(family.name)
+
+	log := httpclient.Logger(ctx)
+	if cfg.Name != "" {
+		log = log.WithField("target", cfg.Name)
+	}
+
+	log.Printf("Checking local %s address...", family.name)
+	ip, method, err := family.detect(ctx, cfg.Network)
+	u.healthCheck.RecordDetection(ipField, method, ip, err)
+	if family.recordType == "AAAA" {
+		u.checkConnectivity(log, ip, err)
+	}
+	if err != nil {
+		log.WithError(err).Errorf("Failed to get %s address", family.name)
+		if u.recordError(err) >= cfg.Health.ErrorThreshold {
+			logrus.Println("Error threshold reached, sending notification...")
+			event := notification.EventDetectFailed
+			if errors.Is(err, iputil.ErrNoPublicIPv6) {
+				event = notification.EventNoPublicIPv6
+			}
+			u.notifyFailure("detect:"+family.recordType, event, family.templateData(err))
+		}
+		return false, err
+	}
+
+	entry := log.WithFields(logrus.Fields{ipField: ip, "domain": cfg.Domain.Domain})
+	entry.WithField("method", method).Printf("Local %s address detected", family.name)
+
+	// 检查缓存，避免重复更新；地址未变化但尚未发布到全部记录(如新增了子域名或更换了服务商)时仍需更新
+	cachedIP, _ := u.cache.GetIP(family.recordType)
+	ipChanged := !u.sameAddress(family, cachedIP, ip)
+	if !ipChanged {
+		delete(u.candidates, family.recordType)
+		metrics.ObservePublishedIP(cfg.Name, family.recordType, cachedIP)
+		unpublished := u.cache.Unpublished(family.recordType, cfg.ProviderName(), cfg.Domain.Hostnames())
+		if len(unpublished) == 0 {
+			entry.Printf("IP未变化，跳过更新")
+			if _, quiet := cfg.Schedule.QuietHours.Until(time.Now()); !quiet {
+				u.resyncPrimary(ctx, entry, family, ip)
+				u.updatePTR(ctx, entry, family, ip)
+			}
+			return false, nil
+		}
+		entry.WithField("records", unpublished).Printf("IP未变化，但尚未发布到这些记录")
+	} else if !u.stable(family, cachedIP, ip) {
+		return false, nil
+	}
+
+	// 静默时段内不更新记录也不写入缓存，时段结束后的检查中再更新
+	if until, quiet := cfg.Schedule.QuietHours.Until(time.Now()); quiet {
+		entry.Printf("Quiet hours until %s, deferring %s update", until.Format("15:04"), family.recordType)
+		u.deferredUntil = until
+		return false, nil
+	}
+
+	if err := u.runPreUpdateHook(ctx, entry, family, cachedIP, ip); err != nil {
+		return false, err
+	}
+
+	entry.Printf("Updating %s records...", family.recordType)
+	subDomains := cfg.Domain.AllSubDomains()
+	var updated, failed []string
+	// rateLimited 所有失败都由服务商限流引起，authFailed 有记录因凭证无效失败
+	rateLimited, authFailed := true, false
+	// 使用重试机制更新DNS记录，服务商支持时合并为批量请求
+	results := dns.UpdateDNSRecordsWithRetry(ctx, u.provider, *cfg, subDomains, family.recordType, ip)
+	viaFallback := u.updateViaFallback(ctx, entry, family, subDomains, ip, results)
+	for i, subDomain := range subDomains {
+		recordEntry := entry.WithField("subdomain", subDomain)
+		if err := results[i]; err != nil {
+			recordEntry.WithError(err).Errorf("Failed to update %s record", family.recordType)
+			failed = append(failed, fmt.Sprintf("%s: %v", subDomain, err))
+			rateLimited = rateLimited && dns.IsRateLimited(err)
+			authFailed = authFailed || dns.IsAuthError(err)
+			continue
+		}
+		name := cfg.Domain.Hostname(subDomain)
+		if cfg.VerifyPropagation && !cfg.DryRun {
+			if err := dns.VerifyRecord(ctx, name, family.recordType, ip, cfg.Verify); err != nil {
+				recordEntry.WithError(err).Errorf("%s record did not propagate", family.recordType)
+				failed = append(failed, fmt.Sprintf("%s: %v", subDomain, err))
+				rateLimited = false
+				continue
+			}
+		}
+		recordEntry.Printf("Updated %s record", family.recordType)
+		updated = append(updated, name)
+	}
+
+	if len(updated) > 0 {
+		entry.WithField("records", updated).Printf("Successfully updated %s records", family.recordType)
+	}
+	if len(failed) > 0 {
+		err = fmt.Errorf("%d/%d records failed: %s", len(failed), len(subDomains), strings.Join(failed, "; "))
+		// 限流只是暂时无法更新，不计入连续错误数，下一轮再试
+		if rateLimited {
+			entry.Warnf("DNS provider is rate limiting requests, %s records will be retried in the next check", family.recordType)
+			return true, err
+		}
+		count := u.recordError(err)
+		// 凭证无效时重试无济于事，不等达到错误阈值立即通知
+		if authFailed {
+			entry.Errorf("DNS provider %s rejected the configured credentials, sending notification...", cfg.ProviderName())
+			u.notifyAuthFailure(err)
+		} else if count >= cfg.Health.ErrorThreshold {
+			logrus.Println("Error threshold reached, sending notification...")
+			data := family.templateData(err)
+			data.IP = ip
+			u.notifyFailure("update:"+family.recordType, notification.EventUpdateFailed, data)
+		}
+		return true, err
+	}
+
+	// 演练模式不更新缓存，使每轮都能看到将要执行的变更
+	if cfg.DryRun {
+		return true, nil
+	}
+
+	// 只有全部记录都确认更新成功才写入缓存，任一记录失败时保留旧地址，下一轮重新更新
+	u.cache.UpdateIP(family.recordType, ip, cfg.ProviderName(), cfg.Domain.Hostnames())
+	delete(u.candidates, family.recordType)
+	u.stalePrimary[family.recordType] = viaFallback
+	u.updateDegraded()
+	u.updatePTR(ctx, entry, family, ip)
+	metrics.ObserveSuccess(cfg.Name)
+	if ipChanged {
+		metrics.ObserveIPChange(cfg.Name, family.recordType, ip)
+		u.healthCheck.RecordChange(family.recordType, ip)
+	}
+
+	if cfg.HistoryFile != "" && ipChanged {
+		err := appendHistory(cfg.HistoryFile, historyEntry{
+			Time:    time.Now(),
+			Target:  cfg.Name,
+			Type:    family.recordType,
+			OldIP:   cachedIP,
+			NewIP:   ip,
+			Records: updated,
+		})
+		if err != nil {
+			entry.WithError(err).Warnf("Failed to write history file %s", cfg.HistoryFile)
+		}
+	}
+
+	if cfg.Hooks.PostUpdate != "" {
+		env := hookEnv{event: "post-update", target: cfg.Name, family: family, oldIP: cachedIP, ip: ip, hostnames: updated}
+		if err := runHook(ctx, cfg.Hooks.PostUpdate, time.Duration(cfg.Hooks.Timeout)*time.Second, env); err != nil {
+			entry.WithError(err).Warn("Post-update hook failed")
+		} else {
+			entry.Println("Post-update hook completed")
+		}
+	}
+
+	// 启动后的首次更新默认不通知，避免每次重启都收到消息
+	firstUpdate := !u.updated[family.recordType]
+	u.updated[family.recordType] = true
+	if ipChanged && cfg.Notifications.NotifyOnChange && (!firstUpdate || cfg.Notifications.NotifyOnStartup) {
+		u.notifyChange(family, cachedIP, ip, updated)
+	}
+	return true, nil
+}
+
+// updatePTR 开启 enablePTR 时将 AAAA 地址的 PTR 记录指向第一个域名；失败只记录日志、不计入连续错误数，
+// 地址未变化时下一轮重试
+func (u *updater) updatePTR(ctx context.Context, entry *logrus.Entry, family ipFamily, ip string) {
+	cfg := u.cfg
+	hostnames := cfg.Domain.Hostnames()
+	if !cfg.EnablePTR || family.recordType != "AAAA" || u.ptrPublished == ip || len(hostnames) == 0 {
+		return
+	}
+
+	published := true
+	for _, addr := range strings.Split(ip, ",") {
+		err := dns.UpdatePTR(ctx, u.provider, *cfg, addr, hostnames[0])
+		switch {
+		case err == nil:
+			entry.Printf("PTR record of %s points to %s", addr, hostnames[0])
+			continue
+		case errors.Is(err, dns.ErrPTRNotDelegated):
+			entry.WithError(err).Errorf("Cannot publish PTR record for %s, the reverse zone must be delegated to %s and added to the account", addr, cfg.ProviderName())
+		default:
+			entry.WithError(err).Errorf("Failed to update PTR record for %s", addr)
+		}
+		published = false
+	}
+	if published && !cfg.DryRun {
+		u.ptrPublished = ip
+	}
+}
+
+// runPreUpdateHook 更新记录前执行 hooks.preUpdate，失败时计为错误并放弃本次更新，下一轮重试；
+// 演练模式只记录将要执行的命令
+func (u *updater) runPreUpdateHook(ctx context.Context, entry *logrus.Entry, family ipFamily, cachedIP, ip string) error {
+	cfg := u.cfg
+	if cfg.Hooks.PreUpdate == "" {
+		return nil
+	}
+	if cfg.DryRun {
+		entry.Printf("[dry-run] Would run pre-update hook: %s", cfg.Hooks.PreUpdate)
+		return nil
+	}
+
+	env := hookEnv{event: "pre-update", target: cfg.Name, family: family, oldIP: cachedIP, ip: ip, hostnames: cfg.Domain.Hostnames()}
+	err := runHook(ctx, cfg.Hooks.PreUpdate, time.Duration(cfg.Hooks.Timeout)*time.Second, env)
+	if err == nil {
+		entry.Println("Pre-update hook completed")
+		return nil
+	}
+	entry.WithError(err).Errorf("Pre-update hook failed, skipping %s update", family.recordType)
+	if u.recordError(err) >= cfg.Health.ErrorThreshold {
+		logrus.Println("Error threshold reached, sending notification...")
+		data := family.templateData(err)
+		data.IP = ip
+		u.notifyFailure("update:"+family.recordType, notification.EventUpdateFailed, data)
+	}
+	return err
+}
+
+// updateViaFallback 主服务商重试耗尽仍失败的记录改用备用服务商更新，更新成功的记录其结果置为 nil，
+// 失败的记录在错误中附上备用服务商的错误；返回经备用服务商更新的子域名
+func (u *updater) updateViaFallback(ctx context.Context, entry *logrus.Entry, family ipFamily, subDomains []string, ip string, results []error) []string {
+	fallback := u.cfg.FallbackConfig()
+	if u.fallback == nil || fallback == nil {
+		return nil
+	}
+	var indexes []int
+	var failedSubs []string
+	for i, err := range results {
+		if err != nil {
+			indexes = append(indexes, i)
+			failedSubs = append(failedSubs, subDomains[i])
+		}
+	}
+	if len(failedSubs) == 0 {
+		return nil
+	}
+
+	entry.WithField("subdomains", failedSubs).Warnf("DNS provider %s failed, updating %s records via fallback provider %s", u.cfg.ProviderName(), family.recordType, fallback.ProviderName())
+	fallbackResults := dns.UpdateDNSRecordsWithRetry(ctx, u.fallback, *fallback, failedSubs, family.recordType, ip)
+	var viaFallback []string
+	authFailed := false
+	for j, i := range indexes {
+		recordEntry := entry.WithField("subdomain", failedSubs[j])
+		if err := fallbackResults[j]; err != nil {
+			recordEntry.WithError(err).Errorf("Fallback provider %s failed to update %s record", fallback.ProviderName(), family.recordType)
+			results[i] = fmt.Errorf("%w; fallback %s: %v", results[i], fallback.ProviderName(), err)
+			continue
+		}
+		recordEntry.Printf("Updated %s record via fallback provider %s", family.recordType, fallback.ProviderName())
+		// 记录已经更新，但主服务商凭证无效仍需尽快通知
+		if dns.IsAuthError(results[i]) && !authFailed {
+			authFailed = true
+			entry.Errorf("DNS provider %s rejected the configured credentials, sending notification...", u.cfg.ProviderName())
+			u.notifyAuthFailure(results[i])
+		}
+		results[i] = nil
+		viaFallback = append(viaFallback, failedSubs[j])
+	}
+	return viaFallback
+}
+
+// resyncPrimary 地址未变化时以主服务商重新更新只由备用服务商更新过的记录，成功后解除降级；
+// 失败不计入连续错误数，下一轮继续尝试
+func (u *updater) resyncPrimary(ctx context.Context, entry *logrus.Entry, family ipFamily, ip string) {
+	// 重新加载配置后已移除的子域名不再同步
+	stale := slices.DeleteFunc(slices.Clone(u.stalePrimary[family.recordType]), func(subDomain string) bool {
+		return !slices.Contains(u.cfg.Domain.AllSubDomains(), subDomain)
+	})
+	if len(stale) == 0 || u.cfg.DryRun {
+		u.stalePrimary[family.recordType] = stale
+		u.updateDegraded()
+		return
+	}
+
+	entry.WithField("subdomains", stale).Printf("Retrying %s records on DNS provider %s, last updated via the fallback provider", family.recordType, u.cfg.ProviderName())
+	results := dns.UpdateDNSRecordsWithRetry(ctx, u.provider, *u.cfg, stale, family.recordType, ip)
+	var remaining []string
+	for i, subDomain := range stale {
+		recordEntry := entry.WithField("subdomain", subDomain)
+		if err := results[i]; err != nil {
+			recordEntry.WithError(err).Warnf("DNS provider %s still failing, %s record remains on the fallback provider only", u.cfg.ProviderName(), family.recordType)
+			remaining = append(remaining, subDomain)
+			continue
+		}
+		recordEntry.Printf("Updated %s record on DNS provider %s", family.recordType, u.cfg.ProviderName())
+	}
+	u.stalePrimary[family.recordType] = remaining
+	u.updateDegraded()
+}
+
+// updateDegraded 按尚未同步到主服务商的记录设置健康检查的降级状态
+func (u *updater) updateDegraded() {
+	var stale []string
+	for _, family := range enabledFamilies(u.cfg) {
+		for _, subDomain := range u.stalePrimary[family.recordType] {
+			stale = append(stale, fmt.Sprintf("%s %s", family.recordType, u.cfg.Domain.Hostname(subDomain)))
+		}
+	}
+	reason := ""
+	if len(stale) > 0 {
+		reason = fmt.Sprintf("updated via the fallback provider, DNS provider %s is out of date for %s", u.cfg.ProviderName(), strings.Join(stale, ", "))
+	}
+	u.healthCheck.SetDegraded(reason)
+}
+
+// stable 记录与已确认地址不同的新地址，连续检测到 StabilityChecks 次后才返回 true。
+// 尚无已确认地址(首次运行)时立即更新；更新失败时候选地址保留，下一轮直接重试。
+func (u *updater) stable(family ipFamily, cachedIP, ip string) bool {
+	required := u.cfg.StabilityChecks
+	if cachedIP == "" || required <= 1 {
+		return true
+	}
+
+	c := u.candidates[family.recordType]
+	if c.ip != "" && u.sameAddress(family, c.ip, ip) {
+		c.count++
+	} else {
+		c = candidate{ip: ip, count: 1}
+	}
+	u.candidates[family.recordType] = c
+
+	if c.count < required {
+		logrus.WithField(strings.ToLower(family.name), ip).Printf("New %s address seen %d/%d times, waiting for it to stabilize", family.name, c.count, required)
+		return false
+	}
+	return true
+}
+
+// sameAddress 判断检测到的地址与缓存相比是否未变化，prefix 模式下 IPv6 只比较网络前缀
+func (u *updater) sameAddress(family ipFamily, cachedIP, ip string) bool {
+	if cachedIP == ip {
+		return true
+	}
+	if cachedIP == "" || family.recordType != "AAAA" || u.cfg.Domain.MatchMode != "prefix" {
+		return false
+	}
+
+	oldPrefix, err := iputil.MaskIPv6(cachedIP, u.cfg.Domain.PrefixLength)
+	if err != nil {
+		return false
+	}
+	newPrefix, err := iputil.MaskIPv6(ip, u.cfg.Domain.PrefixLength)
+	if err != nil {
+		return false
+	}
+	if oldPrefix == newPrefix {
+		logrus.Debugf("Prefix %s unchanged, ignoring interface identifier change %s -> %s", newPrefix, cachedIP, ip)
+		return true
+	}
+	return false
+}
+
+// notifyChange 发送地址变更通知
+func (u *updater) notifyChange(family ipFamily, oldIP, newIP string, records []string) {
+	data := family.templateData(nil)
+	data.IP, data.OldIP, data.Records = newIP, oldIP, records
+	u.notify(notification.EventChange, data)
+}
+
+// recordError 记录一次错误并返回当前连续错误数
+func (u *updater) recordError(err error) int {
+	count := u.healthCheck.RecordError(err)
+	metrics.ObserveFailure(u.cfg.Name, count)
+	return count
+}
+
+// notifyFailure 发送故障通知，同一类故障(key)在冷却时间内只通知一次
+func (u *updater) notifyFailure(key, event string, data notification.TemplateData) {
+	u.sendFailure(key, u.message(event, data))
+}
+
+// sendFailure 按故障类别(key)的冷却时间发送故障通知
+func (u *updater) sendFailure(key string, msg notification.Message) {
+	cooldown := time.Duration(u.cfg.Notifications.NotifyCooldown) * time.Second
+	if last, ok := u.lastFailureNotify[key]; ok && time.Since(last) < cooldown {
+		logrus.Printf("Notification for %s suppressed, last sent %s ago", key, time.Since(last).Round(time.Second))
+		return
+	}
+	msg.Failure = true
+	// 静默时段内未发送时不记录发送时间，故障持续到时段结束后立即通知
+	if u.send(msg) {
+		u.lastFailureNotify[key] = time.Now()
+	}
+}
+
+// notifyAuthFailure 发送凭证无效的紧急通知，同样受通知冷却限制
+func (u *updater) notifyAuthFailure(err error) {
+	msg := u.message(notification.EventAuthFailed, notification.TemplateData{Error: err.Error()})
+	msg.Urgent = true
+	u.sendFailure("auth", msg)
+}
+
+// notify 发送通知，失败时仅记录日志
+func (u *updater) notify(event string, data notification.TemplateData) {
+	u.send(u.message(event, data))
+}
+
+// message 按事件模板创建通知，未指定相关记录时关联全部已配置域名
+func (u *updater) message(event string, data notification.TemplateData) notification.Message {
+	data.Target = u.cfg.Name
+	data.Provider = u.cfg.ProviderName()
+	data.Time = time.Now()
+	if data.Records == nil {
+		data.Records = u.cfg.Domain.Hostnames()
+	}
+	title, body := u.templates.Render(event, data)
+	return notification.Message{
+		Title:     title,
+		Body:      body,
+		IP:        data.IP,
+		OldIP:     data.OldIP,
+		Hostname:  strings.Join(data.Records, ","),
+		Time:      data.Time,
+		RequestID: u.requestID,
+	}
+}
+
+// send 发送通知，失败时仅记录日志；静默时段内暂停非紧急通知(凭证无效等紧急通知照常发送)，
+// 返回通知是否已交给通知器
+func (u *updater) send(msg notification.Message) bool {
+	if until, quiet := u.cfg.Schedule.QuietHours.Until(time.Now()); quiet && !msg.Urgent {
+		logrus.Printf("Notification %q suppressed during quiet hours until %s", msg.Title, until.Format("15:04"))
+		return false
+	}
+	if err := u.notifier.Notify(msg); err != nil {
+		logrus.Printf("Failed to send notification: %v", err)
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"ddns-ipv6/config"
+	"ddns-ipv6/dns"
+	"ddns-ipv6/health"
+)
+
+// failingProvider 更新 fail 中的子域名时返回错误，其余记录更新成功
+type failingProvider struct {
+	mu      sync.Mutex
+	fail    []string
+	updated []string
+}
+
+func (p *failingProvider) UpdateRecord(ctx context.Context, record dns.Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if slices.Contains(p.fail, record.SubDomain) {
+		return errors.New("provider unavailable")
+	}
+	p.updated = append(p.updated, record.Name())
+	return nil
+}
+
+// newTestUpdater 创建使用 provider 的更新器，缓存中已有 cachedIP 发布到全部记录的结果
+func newTestUpdater(t *testing.T, provider dns.Provider, cachedIP string) (*updater, string) {
+	cfg := &config.Config{
+		CacheFile: filepath.Join(t.TempDir(), "cache.json"),
+		Domain:    config.Domain{Domain: "example.com", SubDomains: []string{"@", "www"}, CreateIfMissing: true},
+	}
+	cfg.DNS.Provider = "cloudflare"
+	// 不达到错误阈值，避免发送故障通知
+	cfg.Health.ErrorThreshold = 100
+	cache := dns.NewDNSCache(cfg.CacheFile)
+	cache.UpdateIP("AAAA", cachedIP, "cloudflare", cfg.Domain.Hostnames())
+	healthCheck := health.NewHealthCheck()
+	healthCheck.SetErrorThreshold(cfg.Health.ErrorThreshold)
+	return &updater{
+		cfg:                 cfg,
+		provider:            provider,
+		cache:               cache,
+		healthCheck:         healthCheck,
+		updated:             make(map[string]bool),
+		lastFailureNotify:   make(map[string]time.Time),
+		candidates:          make(map[string]candidate),
+		stalePrimary:        make(map[string][]string),
+		connectivityChecked: true,
+	}, cfg.CacheFile
+}
+
+// detected 返回检测结果固定为 ip 的 IPv6 检测方式
+func detected(ip string) ipFamily {
+	family := familyIPv6
+	family.detect = func(ctx context.Context, cfg config.Network) (string, string, error) {
+		return ip, "test", nil
+	}
+	return family
+}
+
+func TestFailedUpdateKeepsCache(t *testing.T) {
+	const cachedIP, newIP = "2400:3200::1", "2400:3200::2"
+	provider := &failingProvider{fail: []string{"www"}}
+	u, cacheFile := newTestUpdater(t, provider, cachedIP)
+	before, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := u.runFamily(context.Background(), detected(newIP))
+	if !updated || err == nil {
+		t.Fatalf("runFamily = %v, %v, want an update attempt that fails", updated, err)
+	}
+	if !slices.Equal(provider.updated, []string{"example.com"}) {
+		t.Fatalf("provider updated %v, want only example.com", provider.updated)
+	}
+
+	// 部分记录失败时仍保留旧地址及其发布到的服务商与记录
+	if ip, _ := u.cache.GetIP("AAAA"); ip != cachedIP {
+		t.Errorf("cached IP = %s, want %s", ip, cachedIP)
+	}
+	if missing := u.cache.Unpublished("AAAA", "cloudflare", u.cfg.Domain.Hostnames()); len(missing) != 0 {
+		t.Errorf("cached records lost after a failed update, unpublished = %v", missing)
+	}
+	after, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("cache file changed after a failed update:\n%s\nwant:\n%s", after, before)
+	}
+
+	// 下一轮服务商恢复后重新更新全部记录并写入缓存
+	provider.fail = nil
+	if updated, err := u.runFamily(context.Background(), detected(newIP)); !updated || err != nil {
+		t.Fatalf("runFamily after recovery = %v, %v, want a successful update", updated, err)
+	}
+	if ip, _ := u.cache.GetIP("AAAA"); ip != newIP {
+		t.Errorf("cached IP after recovery = %s, want %s", ip, newIP)
+	}
+}